@@ -0,0 +1,242 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Authentication methods that can be configured for a target
+const (
+	authPassword          = "password"
+	authAPIToken          = "apitoken"
+	authClientCredentials = "clientcredentials"
+	authDevice            = "device"
+	authToken             = "token"
+)
+
+var authMethods = []string{authPassword, authAPIToken, authClientCredentials, authDevice, authToken}
+
+// authenticator - obtains a new access token for the current target
+type authenticator interface {
+	// authenticate returns an access token, and the refresh token (API token) to
+	// store for the next run - empty if the method does not issue one
+	authenticate() (accessToken string, refreshToken string, err error)
+}
+
+// getAuthenticator - returns the authenticator for the target's authentication method
+func getAuthenticator(target config) (authenticator, error) {
+	method := strings.ToLower(target.authmethod)
+	if method == "" {
+		// Targets without an explicit method keep the original behaviour
+		if target.isCloud() {
+			method = authAPIToken
+		} else {
+			method = authPassword
+		}
+	}
+	switch method {
+	case authPassword:
+		return passwordAuthenticator{target}, nil
+	case authAPIToken:
+		return apiTokenAuthenticator{target}, nil
+	case authClientCredentials:
+		return clientCredentialsAuthenticator{target}, nil
+	case authDevice:
+		return deviceAuthenticator{target}, nil
+	case authToken:
+		return tokenAuthenticator{}, nil
+	}
+	return nil, errors.New("unknown authentication method " + target.authmethod + ", must be one of " + strings.Join(authMethods, "|"))
+}
+
+// apiTokenAuthenticator - exchanges an API (refresh) token for an access token
+type apiTokenAuthenticator struct {
+	target config
+}
+
+func (a apiTokenAuthenticator) authenticate() (string, string, error) {
+	if a.target.apitoken == "" {
+		return "", "", errors.New("no API token is configured for this target")
+	}
+	accessToken, err := authenticateApiToken(a.target.apitoken)
+	return accessToken, a.target.apitoken, err
+}
+
+// passwordAuthenticator - uses the stored API token if it is still valid, otherwise
+// logs in with username and password (vRA On-premises) to get a new one
+type passwordAuthenticator struct {
+	target config
+}
+
+func (a passwordAuthenticator) authenticate() (string, string, error) {
+	if a.target.apitoken != "" {
+		accessToken, err := authenticateApiToken(a.target.apitoken)
+		if err == nil {
+			return accessToken, a.target.apitoken, nil
+		}
+		log.Debugln("Refresh Token is invalid")
+	}
+	refreshToken, err := authenticateCredentials(a.target.username, a.target.password, a.target.domain)
+	if err != nil {
+		return "", "", err
+	}
+	// Try again, now we have a new API token
+	accessToken, err := authenticateApiToken(refreshToken)
+	return accessToken, refreshToken, err
+}
+
+// clientCredentialsAuthenticator - OAuth client credentials grant for service accounts
+type clientCredentialsAuthenticator struct {
+	target config
+}
+
+func (a clientCredentialsAuthenticator) authenticate() (string, string, error) {
+	log.Debugln("Authenticating with OAuth client credentials")
+	if a.target.clientid == "" || a.target.clientsecret == "" {
+		return "", "", errors.New("client credentials authentication requires a client ID and client secret")
+	}
+	client, err := getRestClient()
+	if err != nil {
+		return "", "", err
+	}
+	queryResponse, err := client.R().
+		SetBasicAuth(a.target.clientid, a.target.clientsecret).
+		SetFormData(map[string]string{"grant_type": "client_credentials"}).
+		SetResult(&AuthenticationResponse{}).
+		SetError(&OAuthError{}).
+		Post(a.target.cspURL() + "/csp/gateway/am/api/auth/authorize")
+	if err != nil {
+		return "", "", err
+	}
+	if queryResponse.IsError() {
+		return "", "", queryResponse.Error().(*OAuthError)
+	}
+	return queryResponse.Result().(*AuthenticationResponse).AccessToken, "", nil
+}
+
+// defaultDeviceCodeExpiry - how long to wait for a device login when the server does not say
+var defaultDeviceCodeExpiry = 10 * time.Minute
+
+// deviceAuthenticator - OAuth device authorization grant, the user completes the
+// login (including SAML/OIDC federated SSO) in a browser
+type deviceAuthenticator struct {
+	target config
+}
+
+func (a deviceAuthenticator) authenticate() (string, string, error) {
+	if a.target.clientid == "" {
+		return "", "", errors.New("device login requires a client ID")
+	}
+	// A refresh token from a previous login avoids prompting the user again
+	if a.target.apitoken != "" {
+		tokens, err := a.requestToken(map[string]string{
+			"grant_type":    "refresh_token",
+			"refresh_token": a.target.apitoken,
+		})
+		if err == nil {
+			return tokens.AccessToken, refreshTokenOrDefault(tokens.RefreshToken, a.target.apitoken), nil
+		}
+		log.Debugln("Refresh Token is invalid:", err)
+	}
+
+	client, err := getRestClient()
+	if err != nil {
+		return "", "", err
+	}
+	queryResponse, err := client.R().
+		SetFormData(map[string]string{"client_id": a.target.clientid}).
+		SetResult(&DeviceAuthorizationResponse{}).
+		SetError(&OAuthError{}).
+		Post(a.target.cspURL() + "/csp/gateway/am/api/auth/device/authorize")
+	if err != nil {
+		return "", "", err
+	}
+	if queryResponse.IsError() {
+		return "", "", queryResponse.Error().(*OAuthError)
+	}
+	device := queryResponse.Result().(*DeviceAuthorizationResponse)
+	verificationURI := device.VerificationURIComplete
+	if verificationURI == "" {
+		verificationURI = device.VerificationURI
+	}
+	// Write the prompt to stderr so it does not end up in redirected command output
+	fmt.Fprintln(os.Stderr, "To sign in, open", verificationURI, "in a browser and enter the code", device.UserCode)
+
+	interval := time.Duration(device.Interval) * time.Second
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	expiresIn := time.Duration(device.ExpiresIn) * time.Second
+	if expiresIn == 0 {
+		expiresIn = defaultDeviceCodeExpiry
+	}
+	deadline := time.Now().Add(expiresIn)
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+		tokens, err := a.requestToken(map[string]string{
+			"grant_type":  "urn:ietf:params:oauth:grant-type:device_code",
+			"device_code": device.DeviceCode,
+		})
+		if err == nil {
+			return tokens.AccessToken, tokens.RefreshToken, nil
+		}
+		var oauthErr *OAuthError
+		if !errors.As(err, &oauthErr) {
+			return "", "", err
+		}
+		switch oauthErr.ErrorCode {
+		case "authorization_pending":
+			log.Debugln("Waiting for the device login to complete")
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return "", "", err
+		}
+	}
+	return "", "", errors.New("device login expired, please try again")
+}
+
+// requestToken - calls the OAuth token endpoint
+func (a deviceAuthenticator) requestToken(formData map[string]string) (*AuthenticationResponse, error) {
+	formData["client_id"] = a.target.clientid
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
+		SetFormData(formData).
+		SetResult(&AuthenticationResponse{}).
+		SetError(&OAuthError{}).
+		Post(a.target.cspURL() + "/csp/gateway/am/api/auth/token")
+	if err != nil {
+		return nil, err
+	}
+	if queryResponse.IsError() {
+		return nil, queryResponse.Error().(*OAuthError)
+	}
+	return queryResponse.Result().(*AuthenticationResponse), nil
+}
+
+// tokenAuthenticator - the target only has a pre-issued access token (e.g. injected
+// by a secrets manager), so there is nothing to refresh it with
+type tokenAuthenticator struct{}
+
+func (a tokenAuthenticator) authenticate() (string, string, error) {
+	return "", "", errors.New("the access token is invalid or expired, and this target uses token-only authentication")
+}
+
+func refreshTokenOrDefault(refreshToken, defaultToken string) string {
+	if refreshToken != "" {
+		return refreshToken
+	}
+	return defaultToken
+}
@@ -5,16 +5,19 @@ SPDX-License-Identifier: BSD-2-Clause
 package cmd
 
 import (
-	"crypto/tls"
+	"errors"
 	"strings"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/mitchellh/mapstructure"
 )
 
 func getCustomIntegration(id, name string) ([]*CodeStreamCustomIntegration, error) {
 	var arrCustomIntegrations []*CodeStreamCustomIntegration
-	client := resty.New()
+	var qParams = make(map[string]string)
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
 
 	var filters []string
 	if id != "" {
@@ -27,12 +30,12 @@ func getCustomIntegration(id, name string) ([]*CodeStreamCustomIntegration, erro
 		qParams["$filter"] = "(" + strings.Join(filters, " and ") + ")"
 	}
 
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/json").
 		SetResult(&documentsList{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Get("https://" + targetConfig.server + "/pipeline/api/custom-integrations")
+		Get(targetConfig.baseURL() + "/pipeline/api/custom-integrations")
 
 	if queryResponse.IsError() {
 		return nil, queryResponse.Error().(error)
@@ -46,6 +49,28 @@ func getCustomIntegration(id, name string) ([]*CodeStreamCustomIntegration, erro
 	return arrCustomIntegrations, err
 }
 
+// deleteCustomIntegration - Delete a Code Stream Custom Integration
+func deleteCustomIntegration(id string) (*CodeStreamCustomIntegration, error) {
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
+		SetQueryParams(qParams).
+		SetHeader("Accept", "application/json").
+		SetResult(&CodeStreamCustomIntegration{}).
+		SetAuthToken(targetConfig.accesstoken).
+		SetError(&CodeStreamException{}).
+		Delete(targetConfig.baseURL() + "/pipeline/api/custom-integrations/" + id)
+	if err != nil {
+		return nil, err
+	}
+	if queryResponse.IsError() {
+		return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
+	}
+	return queryResponse.Result().(*CodeStreamCustomIntegration), nil
+}
+
 // // createCustomIntegration - Create a new Code Stream CustomIntegration
 // func createCustomIntegration(name string, description string, variableType string, project string, value string) (*CodeStreamCustomIntegrationResponse, error) {
 // 	client := resty.New()
@@ -63,7 +88,7 @@ func getCustomIntegration(id, name string) ([]*CodeStreamCustomIntegration, erro
 // 		SetResult(&CodeStreamCustomIntegrationResponse{}).
 // 		SetError(&CodeStreamException{}).
 // 		SetAuthToken(targetConfig.accesstoken).
-// 		Post("https://" + targetConfig.server + "/pipeline/api/variables")
+// 		Post(targetConfig.baseURL() + "/pipeline/api/variables")
 // 	if response.IsError() {
 // 		return nil, errors.New(response.Error().(*CodeStreamException).Message)
 // 	}
@@ -92,28 +117,13 @@ func getCustomIntegration(id, name string) ([]*CodeStreamCustomIntegration, erro
 // 		SetResult(&CodeStreamCustomIntegrationResponse{}).
 // 		SetError(&CodeStreamException{}).
 // 		SetAuthToken(targetConfig.accesstoken).
-// 		Put("https://" + targetConfig.server + "/pipeline/api/variables/" + id)
+// 		Put(targetConfig.baseURL() + "/pipeline/api/variables/" + id)
 // 	if response.IsError() {
 // 		return nil, errors.New(response.Error().(*CodeStreamException).Message)
 // 	}
 // 	return response.Result().(*CodeStreamCustomIntegrationResponse), err
 // }
 
-// // deleteCustomIntegration - Delete a Code Stream CustomIntegration
-// func deleteCustomIntegration(id string) (*CodeStreamCustomIntegrationResponse, error) {
-// 	client := resty.New()
-// 	response, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
-// 		SetHeader("Accept", "application/json").
-// 		SetResult(&CodeStreamCustomIntegrationResponse{}).
-// 		SetAuthToken(targetConfig.accesstoken).
-// 		Delete("https://" + targetConfig.server + "/pipeline/api/variables/" + id)
-// 	if response.IsError() {
-// 		log.Errorln("Create CustomIntegration failed", err)
-// 		os.Exit(1)
-// 	}
-// 	return response.Result().(*CodeStreamCustomIntegrationResponse), err
-// }
-
 // // exportCustomIntegration - Export a variable to YAML
 // func exportCustomIntegration(variable interface{}, exportFile string) {
 // 	// variable will be a CodeStreamCustomIntegrationResponse, so lets remap to CodeStreamCustomIntegrationRequest
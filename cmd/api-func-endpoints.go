@@ -5,12 +5,10 @@ SPDX-License-Identifier: BSD-2-Clause
 package cmd
 
 import (
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"strings"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/mitchellh/mapstructure"
 	log "github.com/sirupsen/logrus"
 )
@@ -19,7 +17,10 @@ func getEndpoint(id, name, project, endpointtype string, exportPath string) ([]*
 	var endpoints []*CodeStreamEndpoint
 	var qParams = make(map[string]string)
 	qParams["expand"] = "true"
-	client := resty.New()
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
 
 	var filters []string
 	if id != "" {
@@ -38,15 +39,18 @@ func getEndpoint(id, name, project, endpointtype string, exportPath string) ([]*
 		qParams["$filter"] = "(" + strings.Join(filters, " and ") + ")"
 	}
 
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/json").
 		SetResult(&documentsList{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Get("https://" + targetConfig.server + "/pipeline/api/endpoints")
-
+		SetError(&CodeStreamException{}).
+		Get(targetConfig.baseURL() + "/pipeline/api/endpoints")
+	if err != nil {
+		return nil, err
+	}
 	if queryResponse.IsError() {
-		return nil, queryResponse.Error().(error)
+		return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
 	}
 
 	for _, value := range queryResponse.Result().(*documentsList).Documents {
@@ -64,15 +68,22 @@ func getEndpoint(id, name, project, endpointtype string, exportPath string) ([]*
 }
 
 func deleteEndpoint(id string) (*CodeStreamEndpoint, error) {
-	client := resty.New()
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/json").
 		SetResult(&CodeStreamEndpoint{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Delete("https://" + targetConfig.server + "/pipeline/api/endpoints/" + id)
+		SetError(&CodeStreamException{}).
+		Delete(targetConfig.baseURL() + "/pipeline/api/endpoints/" + id)
+	if err != nil {
+		return nil, err
+	}
 	if queryResponse.IsError() {
-		return nil, queryResponse.Error().(error)
+		return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
 	}
 	return queryResponse.Result().(*CodeStreamEndpoint), err
 }
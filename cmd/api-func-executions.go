@@ -5,19 +5,103 @@ SPDX-License-Identifier: BSD-2-Clause
 package cmd
 
 import (
-	"crypto/tls"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/mitchellh/mapstructure"
+	"github.com/mrz1836/go-sanitize"
 	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
 )
 
-func getExecutions(id string, project string, status string, name string, nested bool) ([]*CodestreamAPIExecutions, error) {
+// executionFilter - the conditions executions are listed by
+type executionFilter struct {
+	project    string
+	status     string
+	name       string
+	nested     bool
+	since      time.Time
+	until      time.Time
+	executedBy string
+	tags       []string
+	rollback   *bool
+	pipelineID string
+	filter     string // OData passed through as is
+}
+
+// odata - the filter as OData $filter conditions, to be joined with "and"
+func (f executionFilter) odata() []string {
+	var filters []string
+	if f.status != "" {
+		filters = append(filters, "(status eq '"+strings.ToUpper(f.status)+"')")
+	}
+	if f.name != "" {
+		filters = append(filters, "(name eq '"+f.name+"')")
+	}
+	if f.nested {
+		filters = append(filters, "(_nested eq '"+strconv.FormatBool(f.nested)+"')")
+	}
+	if f.project != "" {
+		filters = append(filters, "(project eq '"+f.project+"')")
+	}
+	if !f.since.IsZero() {
+		filters = append(filters, fmt.Sprintf("(_requestTimeInMicros ge %d)", f.since.UnixNano()/1000))
+	}
+	if !f.until.IsZero() {
+		filters = append(filters, fmt.Sprintf("(_requestTimeInMicros lt %d)", f.until.UnixNano()/1000))
+	}
+	if f.executedBy != "" {
+		filters = append(filters, "(_executedBy eq '"+f.executedBy+"')")
+	}
+	for _, tag := range f.tags {
+		filters = append(filters, "(tags.item eq '"+tag+"')")
+	}
+	if f.rollback != nil {
+		filters = append(filters, "(_rollback eq '"+strconv.FormatBool(*f.rollback)+"')")
+	}
+	if f.pipelineID != "" {
+		filters = append(filters, "(_pipelineLink eq '/codestream/api/pipelines/"+f.pipelineID+"')")
+	}
+	if f.filter != "" {
+		filters = append(filters, "("+f.filter+")")
+	}
+	return filters
+}
+
+// executionSortOrders - the --sort values and the OData $orderby for each
+var executionSortOrders = map[string]string{
+	"time":     "_requestTimeInMicros",
+	"duration": "_totalDurationInMicros",
+}
+
+// executionOrderBy - the OData $orderby for a --sort value, newest or longest first unless ascending
+func executionOrderBy(sortBy string, ascending bool) (string, error) {
+	if sortBy == "" {
+		sortBy = "time"
+	}
+	field, ok := executionSortOrders[strings.ToLower(sortBy)]
+	if !ok {
+		return "", fmt.Errorf("unknown sort %q, expected time or duration", sortBy)
+	}
+	if ascending {
+		return field + " asc", nil
+	}
+	return field + " desc", nil
+}
+
+func getExecutions(id string, filter executionFilter, orderBy string) ([]*CodestreamAPIExecutions, error) {
 	var arrExecutions []*CodestreamAPIExecutions
 	if id != "" {
 		x, err := getExecution("/codestream/api/executions/" + id)
@@ -27,75 +111,153 @@ func getExecutions(id string, project string, status string, name string, nested
 		arrExecutions = append(arrExecutions, x)
 		return arrExecutions, err
 	}
-	client := resty.New()
-	var qParams = make(map[string]string)
-
-	qParams["$orderby"] = "_requestTimeInMicros desc"
-	qParams["$top"] = fmt.Sprint(count)
-	qParams["$skip"] = fmt.Sprint(skip)
+	arrExecutions, _, err := queryExecutions(filter.odata(), orderBy, count, skip)
+	return arrExecutions, err
+}
 
-	var filters []string
-	if status != "" {
-		filters = append(filters, "(status eq '"+strings.ToUpper(status)+"')")
-	}
-	if name != "" {
-		filters = append(filters, "(name eq '"+name+"')")
+// queryExecutions - Get one page of executions matching all of the OData filters, in the
+// requested order, along with the total number of matching executions
+func queryExecutions(filters []string, orderBy string, top int, skip int) ([]*CodestreamAPIExecutions, int, error) {
+	var arrExecutions []*CodestreamAPIExecutions
+	documents, totalCount, err := queryExecutionDocuments(filters, orderBy, top, skip)
+	if err != nil {
+		return nil, 0, err
 	}
-	if nested {
-		filters = append(filters, "(_nested eq '"+strconv.FormatBool(nested)+"')")
+	for _, document := range documents {
+		c, err := decodeExecution(document)
+		if err != nil {
+			return nil, 0, err
+		}
+		arrExecutions = append(arrExecutions, c)
 	}
-	if project != "" {
-		filters = append(filters, "(project eq '"+project+"')")
+	return arrExecutions, totalCount, nil
+}
+
+// queryExecutionDocuments - Get one page of raw execution documents, see queryExecutions
+func queryExecutionDocuments(filters []string, orderBy string, top int, skip int) ([]map[string]interface{}, int, error) {
+	var documents []map[string]interface{}
+	client, err := getRestClient()
+	if err != nil {
+		return nil, 0, err
 	}
+	var qParams = make(map[string]string)
+
+	qParams["$orderby"] = orderBy
+	qParams["$top"] = fmt.Sprint(top)
+	qParams["$skip"] = fmt.Sprint(skip)
 	if len(filters) > 0 {
-		qParams["$filter"] = "(" + strings.Join(filters, ") and (") + ")"
+		qParams["$filter"] = "(" + strings.Join(filters, " and ") + ")"
 		log.Debugln(qParams["$filter"])
 	}
 
 	log.Debug(qParams)
 
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/json").
 		SetResult(&documentsList{}).
 		SetError(&CodeStreamException{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Get("https://" + targetConfig.server + "/pipeline/api/executions")
+		Get(targetConfig.baseURL() + "/pipeline/api/executions")
+	if err != nil {
+		return nil, 0, err
+	}
 	if queryResponse.IsError() {
-		//return nil, queryResponse.Error().(error)
-		return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
+		return nil, 0, errors.New(queryResponse.Error().(*CodeStreamException).Message)
 	}
 
-	for _, value := range queryResponse.Result().(*documentsList).Documents {
-		c := CodestreamAPIExecutions{}
-		mapstructure.Decode(value, &c)
-		arrExecutions = append(arrExecutions, &c)
+	// The documents are a map, the links keep the requested order
+	result := queryResponse.Result().(*documentsList)
+	for _, link := range result.Links {
+		if document, ok := result.Documents[link].(map[string]interface{}); ok {
+			documents = append(documents, document)
+		}
 	}
-	return arrExecutions, err
+	return documents, result.TotalCount, nil
+}
+
+// getAllExecutions - Get every execution matching the OData filters, one page (--count) at a time
+func getAllExecutions(filters []string, orderBy string) ([]*CodestreamAPIExecutions, error) {
+	var arrExecutions []*CodestreamAPIExecutions
+	documents, err := getAllExecutionDocuments(filters, orderBy)
+	if err != nil {
+		return nil, err
+	}
+	for _, document := range documents {
+		c, err := decodeExecution(document)
+		if err != nil {
+			return nil, err
+		}
+		arrExecutions = append(arrExecutions, c)
+	}
+	return arrExecutions, nil
+}
+
+// getAllExecutionDocuments - Get every raw execution document matching the OData filters
+func getAllExecutionDocuments(filters []string, orderBy string) ([]map[string]interface{}, error) {
+	var documents []map[string]interface{}
+	pageSize := count
+	if pageSize < 1 {
+		pageSize = 100
+	}
+	for {
+		page, totalCount, err := queryExecutionDocuments(filters, orderBy, pageSize, len(documents))
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, page...)
+		log.Debugln("Fetched", len(documents), "of", totalCount, "executions")
+		if len(page) < pageSize || len(documents) >= totalCount {
+			return documents, nil
+		}
+	}
+}
+
+// decodeExecution - decode an execution document using the JSON field names
+func decodeExecution(document interface{}) (*CodestreamAPIExecutions, error) {
+	c := CodestreamAPIExecutions{}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{TagName: "json", Result: &c})
+	if err != nil {
+		return nil, err
+	}
+	if err := decoder.Decode(document); err != nil {
+		return nil, err
+	}
+	return &c, nil
 }
 
 func getExecution(executionLink string) (*CodestreamAPIExecutions, error) {
-	client := resty.New()
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/json").
 		SetResult(&CodestreamAPIExecutions{}).
+		SetError(&CodeStreamException{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Get("https://" + targetConfig.server + executionLink)
+		Get(targetConfig.baseURL() + executionLink)
+	if err != nil {
+		return nil, err
+	}
 	if queryResponse.IsError() {
-		return nil, queryResponse.Error().(error)
+		return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
 	}
 	return queryResponse.Result().(*CodestreamAPIExecutions), err
 }
 
 func deleteExecution(id string) (*CodestreamAPIExecutions, error) {
-	client := resty.New()
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/json").
 		SetResult(&CodestreamAPIExecutions{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Delete("https://" + targetConfig.server + "/pipeline/api/executions/" + id)
+		Delete(targetConfig.baseURL() + "/pipeline/api/executions/" + id)
 	if queryResponse.IsError() {
 		return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
 	}
@@ -104,7 +266,7 @@ func deleteExecution(id string) (*CodestreamAPIExecutions, error) {
 
 func deleteExecutions(project string, status string, name string, nested bool) ([]*CodestreamAPIExecutions, error) {
 	var deletedExecutions []*CodestreamAPIExecutions
-	Executions, err := getExecutions("", project, status, name, nested)
+	Executions, err := getExecutions("", executionFilter{project: project, status: status, name: name, nested: nested}, "_requestTimeInMicros desc")
 	if err != nil {
 		return nil, err
 	}
@@ -123,34 +285,820 @@ func deleteExecutions(project string, status string, name string, nested bool) (
 	}
 }
 
-func createExecution(id string, inputs string, comment string) (*CodeStreamCreateExecutionResponse, error) {
-	// Convert JSON string to byte array
-	var inputBytes = []byte(inputs)
-	// Unmarshal inputs using a generic interface
-	var inputsInterface interface{}
-	err := json.Unmarshal(inputBytes, &inputsInterface)
-	if err != nil {
-		return nil, err
-	}
+func createExecution(id string, inputs map[string]interface{}, comment string, tags []string) (*CodeStreamCreateExecutionResponse, error) {
 	// Create CodeStreamCreateExecutionRequest struct
 	var execution CodeStreamCreateExecutionRequest
 	execution.Comments = comment
-	execution.Input = inputsInterface
+	execution.Input = inputs
+	execution.Tags = tags
 	//Marshal struct to JSON []byte
 	executionBytes, err := json.Marshal(execution)
 	if err != nil {
 		return nil, err
 	}
-	client := resty.New()
-	queryResponse, _ := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Content-Type", "application/json").
 		SetBody(executionBytes).
 		SetResult(&CodeStreamCreateExecutionResponse{}).
+		SetError(&CodeStreamException{}).
+		SetAuthToken(targetConfig.accesstoken).
+		Post(targetConfig.baseURL() + "/pipeline/api/pipelines/" + id + "/executions")
+	if err != nil {
+		return nil, err
+	}
+	if queryResponse.IsError() {
+		return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
+	}
+	response := queryResponse.Result().(*CodeStreamCreateExecutionResponse)
+	if response.ExecutionLink == "" {
+		return nil, errors.New("the server did not return a link to the execution")
+	}
+	return response, nil
+}
+
+// getExecutionInputs - merges the inputs from an input file (JSON or YAML), a JSON
+// string and key=value pairs, in that order, so later sources override earlier ones
+func getExecutionInputs(inputPath string, inputs string, inputPairs []string) (map[string]interface{}, error) {
+	var executionInputs = make(map[string]interface{})
+	if inputPath != "" {
+		inputBytes, err := ioutil.ReadFile(inputPath)
+		if err != nil {
+			return nil, err
+		}
+		var fileInputs map[string]string
+		if strings.ToLower(filepath.Ext(inputPath)) == ".json" {
+			fileInputs, err = decodeJSONInputs(inputBytes)
+		} else {
+			// Decoded as strings, so numbers such as 1.10 or 007 keep their literal text
+			err = yaml.Unmarshal(inputBytes, &fileInputs)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read inputs from %s: %v", inputPath, err)
+		}
+		for key, value := range fileInputs {
+			executionInputs[key] = value
+		}
+	}
+	if inputs != "" {
+		jsonInputs, err := decodeJSONInputs([]byte(inputs))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read --inputs: %v", err)
+		}
+		for key, value := range jsonInputs {
+			executionInputs[key] = value
+		}
+	}
+	for _, pair := range inputPairs {
+		key := strings.SplitN(pair, "=", 2)
+		if len(key) != 2 || strings.TrimSpace(key[0]) == "" {
+			return nil, fmt.Errorf("invalid input %q, expected key=value", pair)
+		}
+		executionInputs[strings.TrimSpace(key[0])] = key[1]
+	}
+	return executionInputs, nil
+}
+
+// decodeJSONInputs - decodes a JSON object of inputs. Pipeline inputs are strings: numbers keep
+// their literal text, booleans become "true" or "false" and null an empty string.
+func decodeJSONInputs(jsonBytes []byte) (map[string]string, error) {
+	var values map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	jsonInputs := make(map[string]string)
+	for key, value := range values {
+		switch typed := value.(type) {
+		case string:
+			jsonInputs[key] = typed
+		case json.Number:
+			jsonInputs[key] = typed.String()
+		case bool:
+			jsonInputs[key] = strconv.FormatBool(typed)
+		case nil:
+			jsonInputs[key] = ""
+		default:
+			return nil, fmt.Errorf("input %s must be a string, number or boolean", key)
+		}
+	}
+	return jsonInputs, nil
+}
+
+// validateExecutionInputs - checks the inputs against the pipeline input form, rejecting
+// unknown inputs and filling in the default value of any input that was not supplied
+func validateExecutionInputs(pipeline *CodeStreamPipeline, inputs map[string]interface{}) (map[string]interface{}, error) {
+	form, _ := pipeline.Input.(map[string]interface{})
+	var unknown []string
+	for key := range inputs {
+		if _, ok := form[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		var accepted []string
+		for key := range form {
+			accepted = append(accepted, key)
+		}
+		sort.Strings(accepted)
+		if len(accepted) == 0 {
+			return nil, fmt.Errorf("pipeline %s does not accept inputs, got: %s", pipeline.Name, strings.Join(unknown, ", "))
+		}
+		return nil, fmt.Errorf("unknown input(s) for pipeline %s: %s (accepted inputs: %s)", pipeline.Name, strings.Join(unknown, ", "), strings.Join(accepted, ", "))
+	}
+	var validated = make(map[string]interface{})
+	for key, defaultValue := range form {
+		if value, ok := inputs[key]; ok {
+			validated[key] = value
+		} else {
+			log.Debugln("Using default value for input", key)
+			validated[key] = defaultValue
+		}
+	}
+	return validated, nil
+}
+
+// executionFinished - true if the execution status is final
+func executionFinished(status string) bool {
+	switch strings.ToUpper(status) {
+	case "COMPLETED", "FAILED", "CANCELED", "ROLLBACK_COMPLETED", "ROLLBACK_FAILED":
+		return true
+	}
+	return false
+}
+
+// errExecutionTimeout - the execution had not finished, or not started, by the deadline
+var errExecutionTimeout = errors.New("timed out")
+
+// waitForExecution - polls the execution until it has finished. If the deadline (when not zero)
+// passes first, the last state of the execution is returned with errExecutionTimeout.
+func waitForExecution(executionLink string, interval time.Duration, deadline time.Time) (*CodestreamAPIExecutions, error) {
+	for {
+		execution, err := getExecution(executionLink)
+		if err != nil {
+			return nil, err
+		}
+		if executionFinished(execution.Status) {
+			return execution, nil
+		}
+		log.Debugln(executionLink, execution.Status)
+		wait := interval
+		if !deadline.IsZero() {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return execution, errExecutionTimeout
+			}
+			if remaining < wait {
+				wait = remaining
+			}
+		}
+		time.Sleep(wait)
+	}
+}
+
+// getMatrixInputs - reads a matrix file and returns the inputs for each execution. Every
+// combination of the matrix values is used, followed by each include entry, and the
+// shared inputs are added to all of them.
+func getMatrixInputs(matrixPath string) (combinations []map[string]interface{}, shared map[string]interface{}, err error) {
+	matrixBytes, err := ioutil.ReadFile(matrixPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	var matrix CodeStreamExecutionMatrix
+	if err := yaml.Unmarshal(matrixBytes, &matrix); err != nil {
+		return nil, nil, fmt.Errorf("unable to read matrix from %s: %v", matrixPath, err)
+	}
+	inputMap := func(values map[string]string) map[string]interface{} {
+		m := make(map[string]interface{})
+		for key, value := range values {
+			m[key] = value
+		}
+		return m
+	}
+	var keys []string
+	for key, values := range matrix.Matrix {
+		if len(values) == 0 {
+			return nil, nil, fmt.Errorf("matrix input %s has no values", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		combinations = []map[string]interface{}{{}}
+		for _, key := range keys {
+			var expanded []map[string]interface{}
+			for _, combination := range combinations {
+				for _, value := range matrix.Matrix[key] {
+					c := map[string]interface{}{key: value}
+					for k, v := range combination {
+						c[k] = v
+					}
+					expanded = append(expanded, c)
+				}
+			}
+			combinations = expanded
+		}
+	}
+	for _, include := range matrix.Include {
+		combinations = append(combinations, inputMap(include))
+	}
+	if len(combinations) == 0 {
+		return nil, nil, errors.New("the matrix file has no matrix or include entries")
+	}
+	return combinations, inputMap(matrix.Inputs), nil
+}
+
+// matrixExecution - an execution started for one combination of matrix inputs
+type matrixExecution struct {
+	Combination map[string]interface{}
+	Execution   *CodestreamAPIExecutions
+	Err         error
+}
+
+// runExecutionMatrix - starts an execution of the pipeline for each combination of inputs,
+// never running more than concurrency at once, and waits for all of them to finish. Nothing is
+// started if the inputs of any combination are invalid. Executions that have not finished, or not
+// started, when the deadline (when not zero) passes fail with errExecutionTimeout.
+func runExecutionMatrix(pipeline *CodeStreamPipeline, combinations []map[string]interface{}, shared map[string]interface{}, comment string, tags []string, concurrency int, interval time.Duration, deadline time.Time) ([]*matrixExecution, error) {
+	// Validate every combination before starting anything
+	var inputs []map[string]interface{}
+	for _, combination := range combinations {
+		combinationInputs := make(map[string]interface{})
+		for key, value := range shared {
+			combinationInputs[key] = value
+		}
+		for key, value := range combination {
+			combinationInputs[key] = value
+		}
+		combinationInputs, err := validateExecutionInputs(pipeline, combinationInputs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matrix entry %s: %v", formatInputs(combination), err)
+		}
+		inputs = append(inputs, combinationInputs)
+	}
+
+	results := make([]*matrixExecution, len(combinations))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range combinations {
+		results[i] = &matrixExecution{Combination: combinations[i]}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(result *matrixExecution, executionInputs map[string]interface{}) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if !deadline.IsZero() && !time.Now().Before(deadline) {
+				result.Err = errExecutionTimeout
+				return
+			}
+			response, err := createExecution(pipeline.ID, executionInputs, comment, tags)
+			if err != nil {
+				log.Warnln("Unable to create execution for", formatInputs(result.Combination)+":", err)
+				result.Err = err
+				return
+			}
+			log.Infoln("Execution " + response.ExecutionLink + " created for " + formatInputs(result.Combination))
+			result.Execution, result.Err = waitForExecution(response.ExecutionLink, interval, deadline)
+			if result.Err == nil {
+				log.Infoln("Execution "+response.ExecutionLink, result.Execution.Status)
+			} else if result.Err == errExecutionTimeout {
+				log.Warnln("Execution "+response.ExecutionLink, "timed out while", result.Execution.Status)
+			}
+		}(results[i], inputs[i])
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// formatInputs - key=value pairs, sorted by key
+func formatInputs(inputs map[string]interface{}) string {
+	var pairs []string
+	for key, value := range inputs {
+		pairs = append(pairs, key+"="+fmt.Sprint(value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// executionReport - execution statistics for one pipeline
+type executionReport struct {
+	Pipeline          string                 `json:"pipeline"`
+	Project           string                 `json:"project"`
+	Runs              int                    `json:"runs"`
+	Completed         int                    `json:"completed"`
+	Failed            int                    `json:"failed"`
+	Running           int                    `json:"running"`
+	SuccessRate       float64                `json:"successRate"`
+	P50DurationMicros int64                  `json:"p50DurationInMicros"`
+	P95DurationMicros int64                  `json:"p95DurationInMicros"`
+	TopFailingTask    string                 `json:"topFailingTask,omitempty"`
+	TopFailingCount   int                    `json:"topFailingTaskCount,omitempty"`
+	TopStatusMessages []executionReportCount `json:"topStatusMessages,omitempty"`
+}
+
+// executionReportCount - a value and the number of times it was seen
+type executionReportCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// reportExecutions - aggregate the executions by pipeline, sorted by project and pipeline name
+func reportExecutions(executions []*CodestreamAPIExecutions, topMessages int) []*executionReport {
+	type pipelineExecutions struct {
+		report    *executionReport
+		durations []int64
+		failures  map[string]int
+		messages  map[string]int
+	}
+	byPipeline := make(map[string]*pipelineExecutions)
+	var keys []string
+	for _, execution := range executions {
+		key := execution.Project + "/" + execution.Name
+		p, ok := byPipeline[key]
+		if !ok {
+			p = &pipelineExecutions{
+				report:   &executionReport{Pipeline: execution.Name, Project: execution.Project},
+				failures: make(map[string]int),
+				messages: make(map[string]int),
+			}
+			byPipeline[key] = p
+			keys = append(keys, key)
+		}
+		p.report.Runs++
+		switch {
+		case !executionFinished(execution.Status):
+			p.report.Running++
+			continue
+		case execution.Status == "COMPLETED":
+			p.report.Completed++
+		default:
+			p.report.Failed++
+			if task := failingTask(execution); task != "" {
+				p.failures[task]++
+			}
+			if execution.StatusMessage != "" {
+				p.messages[execution.StatusMessage]++
+			}
+		}
+		p.durations = append(p.durations, int64(execution.TotalDurationInMicros))
+	}
+	sort.Strings(keys)
+
+	var reports []*executionReport
+	for _, key := range keys {
+		p := byPipeline[key]
+		if finished := p.report.Completed + p.report.Failed; finished > 0 {
+			p.report.SuccessRate = float64(p.report.Completed) / float64(finished) * 100
+		}
+		sort.Slice(p.durations, func(i, j int) bool { return p.durations[i] < p.durations[j] })
+		p.report.P50DurationMicros = percentile(p.durations, 50)
+		p.report.P95DurationMicros = percentile(p.durations, 95)
+		if failures := sortCounts(p.failures); len(failures) > 0 {
+			p.report.TopFailingTask = failures[0].Value
+			p.report.TopFailingCount = failures[0].Count
+		}
+		p.report.TopStatusMessages = sortCounts(p.messages)
+		if len(p.report.TopStatusMessages) > topMessages {
+			p.report.TopStatusMessages = p.report.TopStatusMessages[:topMessages]
+		}
+		reports = append(reports, p.report)
+	}
+	return reports
+}
+
+// failingTask - the first failed task (Stage.Task) of an execution, or the failed stage
+// if none of its tasks failed
+func failingTask(execution *CodestreamAPIExecutions) string {
+	stages, _ := execution.Stages.(map[string]interface{})
+	stageNames := make([]string, 0, len(stages))
+	for _, stageName := range execution.StageOrder {
+		stageNames = append(stageNames, fmt.Sprint(stageName))
+	}
+	if len(stageNames) == 0 {
+		for stageName := range stages {
+			stageNames = append(stageNames, stageName)
+		}
+		sort.Strings(stageNames)
+	}
+	for _, stageName := range stageNames {
+		stage, _ := stages[stageName].(map[string]interface{})
+		if !strings.EqualFold(fmt.Sprint(stage["status"]), "FAILED") {
+			continue
+		}
+		tasks, _ := stage["tasks"].(map[string]interface{})
+		var taskNames []string
+		for taskName, t := range tasks {
+			if task, ok := t.(map[string]interface{}); ok && strings.EqualFold(fmt.Sprint(task["status"]), "FAILED") {
+				taskNames = append(taskNames, taskName)
+			}
+		}
+		if len(taskNames) > 0 {
+			sort.Strings(taskNames)
+			return stageName + "." + taskNames[0]
+		}
+		return stageName
+	}
+	return ""
+}
+
+// percentile - nearest-rank percentile of sorted values
+func percentile(sorted []int64, p int) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// sortCounts - the counted values, most frequent first
+func sortCounts(counts map[string]int) []executionReportCount {
+	var sorted []executionReportCount
+	for value, count := range counts {
+		sorted = append(sorted, executionReportCount{Value: value, Count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Value < sorted[j].Value
+	})
+	return sorted
+}
+
+// executionPrunePlan - the executions of one pipeline, and those to delete
+type executionPrunePlan struct {
+	Pipeline   string
+	Project    string
+	Executions int
+	Delete     []*CodestreamAPIExecutions
+	Deleted    int
+	Errors     int
+}
+
+// planExecutionPrune - works out which executions to delete for each pipeline. An execution is
+// deleted when it is not one of the keepLast most recent runs of its pipeline, was requested
+// before olderThan and has one of the statuses. Unfinished executions and the latest run of each
+// status are always kept. Executions must be sorted newest first.
+func planExecutionPrune(executions []*CodestreamAPIExecutions, keepLast int, olderThan time.Time, statuses []string) []*executionPrunePlan {
+	var plans []*executionPrunePlan
+	byPipeline := make(map[string]*executionPrunePlan)
+	latest := make(map[string]bool) // pipeline and status -> the latest run has been seen
+	for _, execution := range executions {
+		key := execution.Project + "/" + execution.Name
+		plan, ok := byPipeline[key]
+		if !ok {
+			plan = &executionPrunePlan{Pipeline: execution.Name, Project: execution.Project}
+			byPipeline[key] = plan
+			plans = append(plans, plan)
+		}
+		plan.Executions++
+		status := strings.ToUpper(execution.Status)
+		if !latest[key+"/"+status] {
+			latest[key+"/"+status] = true
+			continue
+		}
+		if plan.Executions <= keepLast || !executionFinished(status) {
+			continue
+		}
+		if !olderThan.IsZero() && execution.RequestTimeInMicros >= olderThan.UnixNano()/1000 {
+			continue
+		}
+		if len(statuses) > 0 && !containsFold(statuses, status) {
+			continue
+		}
+		plan.Delete = append(plan.Delete, execution)
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		if plans[i].Project != plans[j].Project {
+			return plans[i].Project < plans[j].Project
+		}
+		return plans[i].Pipeline < plans[j].Pipeline
+	})
+	return plans
+}
+
+// pruneExecutions - deletes the planned executions, at most concurrency at a time
+func pruneExecutions(plans []*executionPrunePlan, concurrency int) {
+	var total int
+	for _, plan := range plans {
+		total += len(plan.Delete)
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var mu sync.Mutex
+	var done int
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, plan := range plans {
+		for _, execution := range plan.Delete {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(plan *executionPrunePlan, execution *CodestreamAPIExecutions) {
+				defer func() {
+					<-slots
+					wg.Done()
+				}()
+				_, err := deleteExecution(execution.ID)
+				mu.Lock()
+				defer mu.Unlock()
+				done++
+				if err != nil {
+					plan.Errors++
+					log.Warnf("[%d/%d] Unable to delete %s#%d: %v", done, total, execution.Name, execution.Index, err)
+					return
+				}
+				plan.Deleted++
+				log.Infof("[%d/%d] Deleted %s#%d", done, total, execution.Name, execution.Index)
+			}(plan, execution)
+		}
+	}
+	wg.Wait()
+}
+
+// containsFold - true if the slice contains the string, ignoring case
+func containsFold(slice []string, s string) bool {
+	for _, v := range slice {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// executionIndex - the index file written by exportExecutions
+type executionIndex struct {
+	Project    string                `json:"project"`
+	Name       string                `json:"name,omitempty"`
+	ExportedAt string                `json:"exportedAt"`
+	Executions []executionIndexEntry `json:"executions"`
+}
+
+// executionIndexEntry - an exported execution
+type executionIndexEntry struct {
+	ID                  string `json:"id"`
+	Pipeline            string `json:"pipeline"`
+	Index               int    `json:"index"`
+	Status              string `json:"status"`
+	RequestTimeInMicros int64  `json:"requestTimeInMicros"`
+	ExecutedBy          string `json:"executedBy"`
+	Comments            string `json:"comments,omitempty"`
+	File                string `json:"file"`
+}
+
+// getExecutionLogs - the workspace logs of an execution, nil if the server has none for it
+func getExecutionLogs(id string) (interface{}, error) {
+	var logs interface{}
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
+		SetQueryParams(qParams).
+		SetHeader("Accept", "application/json").
+		SetResult(&logs).
+		SetError(&CodeStreamException{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Post("https://" + targetConfig.server + "/pipeline/api/pipelines/" + id + "/executions")
+		Get(targetConfig.baseURL() + "/codestream/api/executions/" + id + "/logs")
+	if err != nil {
+		return nil, err
+	}
+	if queryResponse.StatusCode() == http.StatusNotFound {
+		return nil, nil
+	}
 	if queryResponse.IsError() {
 		return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
 	}
-	return queryResponse.Result().(*CodeStreamCreateExecutionResponse), nil
+	return logs, nil
+}
+
+// exportedPipelines - describes the pipelines selected by the --name of an export
+func exportedPipelines(name string) string {
+	if name == "" {
+		return "all pipelines"
+	}
+	return "pipeline " + name
+}
+
+// exportExecutions - writes each execution of the project requested since the given time, with
+// its workspace logs, to its own JSON file in outDir, and lists them in outDir/index.json. The
+// index is saved after each execution, so an export that fails part way can be resumed. Executions
+// that were already exported once finished are not fetched or written again.
+func exportExecutions(project string, name string, since time.Time, outDir string) (written int, total int, err error) {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return 0, 0, err
+	}
+	indexPath := filepath.Join(outDir, "index.json")
+	var index executionIndex
+	if indexBytes, err := ioutil.ReadFile(indexPath); err == nil {
+		if err := json.Unmarshal(indexBytes, &index); err != nil {
+			return 0, 0, fmt.Errorf("unable to read %s: %v", indexPath, err)
+		}
+		if index.Project != "" && index.Project != project {
+			return 0, 0, fmt.Errorf("%s is an export of project %s, not %s", outDir, index.Project, project)
+		}
+		// The incremental bounds come from the index, so they only hold for the same pipelines
+		if index.Name != name {
+			return 0, 0, fmt.Errorf("%s is an export of %s, not %s", outDir, exportedPipelines(index.Name), exportedPipelines(name))
+		}
+	} else if !os.IsNotExist(err) {
+		return 0, 0, err
+	}
+	index.Project = project
+	index.Name = name
+
+	// Only fetch executions newer than the last export, or the oldest one that had not finished
+	from := since.UnixNano() / 1000
+	entries := make(map[string]int)
+	var newest, oldestUnfinished int64
+	for i, entry := range index.Executions {
+		entries[entry.ID] = i
+		if entry.RequestTimeInMicros > newest {
+			newest = entry.RequestTimeInMicros
+		}
+		if !executionFinished(entry.Status) && (oldestUnfinished == 0 || entry.RequestTimeInMicros < oldestUnfinished) {
+			oldestUnfinished = entry.RequestTimeInMicros
+		}
+	}
+	incremental := newest
+	if oldestUnfinished != 0 {
+		incremental = oldestUnfinished
+	}
+	if incremental > from {
+		from = incremental
+	}
+	saveIndex := func() error {
+		sort.SliceStable(index.Executions, func(i, j int) bool {
+			return index.Executions[i].RequestTimeInMicros > index.Executions[j].RequestTimeInMicros
+		})
+		for i, entry := range index.Executions {
+			entries[entry.ID] = i
+		}
+		index.ExportedAt = time.Now().UTC().Format(time.RFC3339)
+		indexBytes, err := json.MarshalIndent(index, "", "  ")
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(indexPath, indexBytes, 0644)
+	}
+
+	filter := executionFilter{project: project, name: name, since: time.Unix(0, from*1000)}
+	// Oldest first, so the index of an export that failed part way has no gaps to resume from
+	documents, err := getAllExecutionDocuments(filter.odata(), "_requestTimeInMicros asc")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, document := range documents {
+		execution, err := decodeExecution(document)
+		if err != nil {
+			return written, len(index.Executions), err
+		}
+		i, exported := entries[execution.ID]
+		if exported && executionFinished(index.Executions[i].Status) {
+			continue
+		}
+		entry := executionIndexEntry{
+			ID:                  execution.ID,
+			Pipeline:            execution.Name,
+			Index:               execution.Index,
+			Status:              execution.Status,
+			RequestTimeInMicros: execution.RequestTimeInMicros,
+			ExecutedBy:          execution.ExecutedBy,
+			Comments:            execution.Comments,
+			File:                fmt.Sprintf("%s-%d-%s.json", sanitize.PathName(execution.Name), execution.Index, execution.ID),
+		}
+		logs, err := getExecutionLogs(execution.ID)
+		if err != nil {
+			return written, len(index.Executions), fmt.Errorf("unable to get the logs of %s #%d: %v", execution.Name, execution.Index, err)
+		}
+		if logs != nil {
+			document["workspaceLogs"] = logs
+		}
+		executionBytes, err := json.MarshalIndent(document, "", "  ")
+		if err != nil {
+			return written, len(index.Executions), err
+		}
+		if err := ioutil.WriteFile(filepath.Join(outDir, entry.File), executionBytes, 0644); err != nil {
+			return written, len(index.Executions), err
+		}
+		log.Debugln("Exported", entry.File)
+		written++
+		if exported {
+			if previous := index.Executions[i].File; previous != entry.File {
+				os.Remove(filepath.Join(outDir, previous))
+			}
+			index.Executions[i] = entry
+		} else {
+			index.Executions = append(index.Executions, entry)
+		}
+		if err := saveIndex(); err != nil {
+			return written, len(index.Executions), err
+		}
+	}
+	return written, len(index.Executions), saveIndex()
+}
+
+// unfinishedExecutionsFilter - OData filter for executions that have not finished
+const unfinishedExecutionsFilter = "status ne 'COMPLETED' and status ne 'FAILED' and status ne 'CANCELED' and status ne 'ROLLBACK_COMPLETED' and status ne 'ROLLBACK_FAILED'"
+
+// mergeExecutions - the executions in both lists without duplicates, newest first
+func mergeExecutions(a []*CodestreamAPIExecutions, b []*CodestreamAPIExecutions) []*CodestreamAPIExecutions {
+	seen := make(map[string]bool)
+	var merged []*CodestreamAPIExecutions
+	for _, execution := range append(append([]*CodestreamAPIExecutions{}, a...), b...) {
+		if !seen[execution.ID] {
+			seen[execution.ID] = true
+			merged = append(merged, execution)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].RequestTimeInMicros > merged[j].RequestTimeInMicros
+	})
+	return merged
+}
+
+// executionStage - the stage (or Stage.Task) a failed execution failed in, or the first
+// stage of a running execution that has not completed
+func executionStage(execution *CodestreamAPIExecutions) string {
+	if strings.EqualFold(execution.Status, "FAILED") {
+		return failingTask(execution)
+	}
+	if executionFinished(execution.Status) {
+		return ""
+	}
+	stages, _ := execution.Stages.(map[string]interface{})
+	for _, stageName := range execution.StageOrder {
+		stage, _ := stages[fmt.Sprint(stageName)].(map[string]interface{})
+		switch strings.ToUpper(fmt.Sprint(stage["status"])) {
+		case "COMPLETED", "SKIPPED":
+			continue
+		}
+		return fmt.Sprint(stageName)
+	}
+	return ""
+}
+
+// executionWebhookPayload - the body posted to a webhook when an execution fails. The text
+// field makes it usable as a Slack or Teams incoming webhook.
+type executionWebhookPayload struct {
+	Text      string                  `json:"text"`
+	Execution executionWebhookDetails `json:"execution"`
+}
+
+// executionWebhookDetails - the failed execution
+type executionWebhookDetails struct {
+	ID            string `json:"id"`
+	Pipeline      string `json:"pipeline"`
+	Project       string `json:"project"`
+	Index         int    `json:"index"`
+	Status        string `json:"status"`
+	StatusMessage string `json:"statusMessage"`
+	Stage         string `json:"stage,omitempty"`
+	ExecutedBy    string `json:"executedBy,omitempty"`
+	Link          string `json:"link"`
+}
+
+// notifyExecutionFailed - posts the failed execution to the webhook
+func notifyExecutionFailed(webhookURL string, execution *CodestreamAPIExecutions) error {
+	payload := executionWebhookPayload{
+		Text: fmt.Sprintf("Execution %s#%d in %s %s: %s", execution.Name, execution.Index, execution.Project, execution.Status, execution.StatusMessage),
+		Execution: executionWebhookDetails{
+			ID:            execution.ID,
+			Pipeline:      execution.Name,
+			Project:       execution.Project,
+			Index:         execution.Index,
+			Status:        execution.Status,
+			StatusMessage: execution.StatusMessage,
+			Stage:         executionStage(execution),
+			ExecutedBy:    execution.ExecutedBy,
+			Link:          targetConfig.baseURL() + "/codestream/#/executions/" + execution.ID,
+		},
+	}
+	client := resty.New()
+	if targetConfig.proxy != "" {
+		client.SetProxy(targetConfig.proxy)
+	}
+	response, err := client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(payload).
+		Post(webhookURL)
+	if err != nil {
+		return err
+	}
+	if response.IsError() {
+		return fmt.Errorf("webhook returned %s", response.Status())
+	}
+	return nil
 }
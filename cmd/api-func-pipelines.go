@@ -5,19 +5,29 @@ SPDX-License-Identifier: BSD-2-Clause
 package cmd
 
 import (
-	"crypto/tls"
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/mitchellh/mapstructure"
+	"github.com/mrz1836/go-sanitize"
 	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
 )
 
 func getPipelines(id string, name string, project string, exportPath string) ([]*CodeStreamPipeline, error) {
 	var arrResults []*CodeStreamPipeline
-	client := resty.New()
+	var qParams = make(map[string]string)
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
 
 	var filters []string
 	if id != "" {
@@ -32,13 +42,13 @@ func getPipelines(id string, name string, project string, exportPath string) ([]
 	if len(filters) > 0 {
 		qParams["$filter"] = "(" + strings.Join(filters, " and ") + ")"
 	}
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/json").
 		SetResult(&documentsList{}).
 		SetAuthToken(targetConfig.accesstoken).
 		SetError(&CodeStreamException{}).
-		Get("https://" + targetConfig.server + "/pipeline/api/pipelines")
+		Get(targetConfig.baseURL() + "/pipeline/api/pipelines")
 
 	log.Debugln(queryResponse.Request.RawRequest.URL)
 	// log.Debugln(queryResponse.String())
@@ -62,32 +72,75 @@ func getPipelines(id string, name string, project string, exportPath string) ([]
 	return arrResults, err
 }
 
+// getPipeline - Get a single Code Stream Pipeline by ID, or by name and (optionally) project.
+// A name that matches pipelines in more than one project is refused.
+func getPipeline(id string, name string, project string) (*CodeStreamPipeline, error) {
+	if id == "" && name == "" {
+		return nil, errors.New("either --id or --name is required")
+	}
+	if id != "" {
+		name, project = "", ""
+	}
+	pipelines, err := getPipelines(id, name, project, "")
+	if err != nil {
+		return nil, err
+	}
+	switch len(pipelines) {
+	case 0:
+		if id != "" {
+			return nil, errors.New("pipeline " + id + " not found")
+		}
+		if project != "" {
+			return nil, errors.New("pipeline " + name + " not found in project " + project)
+		}
+		return nil, errors.New("pipeline " + name + " not found")
+	case 1:
+		return pipelines[0], nil
+	}
+	var projects []string
+	for _, p := range pipelines {
+		projects = append(projects, p.Project)
+	}
+	sort.Strings(projects)
+	return nil, fmt.Errorf("pipeline %s exists in more than one project (%s), use --project or --id", name, strings.Join(projects, ", "))
+}
+
 // patchPipeline - Patch Code Stream Pipeline by ID
 func patchPipeline(id string, payload string) (*CodeStreamPipeline, error) {
-	client := resty.New()
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/json").
 		SetHeader("Content-Type", "application/json").
 		SetBody(payload).
 		SetResult(&CodeStreamPipeline{}).
+		SetError(&CodeStreamException{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Patch("https://" + targetConfig.server + "/pipeline/api/pipelines/" + id)
+		Patch(targetConfig.baseURL() + "/pipeline/api/pipelines/" + id)
+	if err != nil {
+		return nil, err
+	}
 	if queryResponse.IsError() {
-		return nil, queryResponse.Error().(error)
+		return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
 	}
 	return queryResponse.Result().(*CodeStreamPipeline), err
 }
 
 func deletePipeline(id string) (*CodeStreamPipeline, error) {
-	client := resty.New()
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/json").
 		SetResult(&CodeStreamPipeline{}).
 		SetAuthToken(targetConfig.accesstoken).
 		SetError(&CodeStreamException{}).
-		Delete("https://" + targetConfig.server + "/pipeline/api/pipelines/" + id)
+		Delete(targetConfig.baseURL() + "/pipeline/api/pipelines/" + id)
 	if queryResponse.IsError() {
 		return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
 	}
@@ -114,3 +167,568 @@ func deletePipelineInProject(project string) ([]*CodeStreamPipeline, error) {
 		return nil, errors.New("user declined")
 	}
 }
+
+// queryPipelines - Get one page of pipelines matching all of the OData filters, sorted by name,
+// along with the total number of matching pipelines
+func queryPipelines(filters []string, top int, skip int) ([]*CodeStreamPipeline, int, error) {
+	var arrResults []*CodeStreamPipeline
+	client, err := getRestClient()
+	if err != nil {
+		return nil, 0, err
+	}
+	var qParams = make(map[string]string)
+
+	qParams["$orderby"] = "name asc"
+	qParams["$top"] = fmt.Sprint(top)
+	qParams["$skip"] = fmt.Sprint(skip)
+	if len(filters) > 0 {
+		qParams["$filter"] = "(" + strings.Join(filters, " and ") + ")"
+	}
+	queryResponse, err := client.R().
+		SetQueryParams(qParams).
+		SetHeader("Accept", "application/json").
+		SetResult(&documentsList{}).
+		SetAuthToken(targetConfig.accesstoken).
+		SetError(&CodeStreamException{}).
+		Get(targetConfig.baseURL() + "/pipeline/api/pipelines")
+	if err != nil {
+		return nil, 0, err
+	}
+	if queryResponse.IsError() {
+		return nil, 0, errors.New(queryResponse.Error().(*CodeStreamException).Message)
+	}
+	result := queryResponse.Result().(*documentsList)
+	for _, link := range result.Links {
+		c := CodeStreamPipeline{}
+		mapstructure.Decode(result.Documents[link], &c)
+		arrResults = append(arrResults, &c)
+	}
+	return arrResults, result.TotalCount, nil
+}
+
+// getAllPipelines - Get every pipeline matching the OData filters, one page (--count) at a time
+func getAllPipelines(filters []string) ([]*CodeStreamPipeline, error) {
+	var arrResults []*CodeStreamPipeline
+	pageSize := count
+	if pageSize < 1 {
+		pageSize = 100
+	}
+	for {
+		page, totalCount, err := queryPipelines(filters, pageSize, len(arrResults))
+		if err != nil {
+			return nil, err
+		}
+		arrResults = append(arrResults, page...)
+		if len(page) < pageSize || len(arrResults) >= totalCount {
+			return arrResults, nil
+		}
+	}
+}
+
+var pipelineIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// selectPipelines - Get the pipelines listed in listPath (one name or ID per line, # for comments),
+// or else every pipeline matching the name and project with all of the tags
+func selectPipelines(name string, project string, tags []string, listPath string) ([]*CodeStreamPipeline, error) {
+	var selected []*CodeStreamPipeline
+	if listPath != "" {
+		listBytes, err := ioutil.ReadFile(listPath)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, line := range strings.Split(string(listBytes), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			var pipeline *CodeStreamPipeline
+			if pipelineIDPattern.MatchString(line) {
+				pipeline, err = getPipeline(line, "", "")
+			} else {
+				pipeline, err = getPipeline("", line, project)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", listPath, err)
+			}
+			if !seen[pipeline.ID] {
+				seen[pipeline.ID] = true
+				selected = append(selected, pipeline)
+			}
+		}
+		return selected, nil
+	}
+
+	var filters []string
+	if name != "" {
+		filters = append(filters, "(name eq '"+name+"')")
+	}
+	if project != "" {
+		filters = append(filters, "(project eq '"+project+"')")
+	}
+	pipelines, err := getAllPipelines(filters)
+	if err != nil {
+		return nil, err
+	}
+	for _, pipeline := range pipelines {
+		matches := true
+		for _, tag := range tags {
+			if !containsFold(pipeline.Tags, tag) {
+				matches = false
+			}
+		}
+		if matches {
+			selected = append(selected, pipeline)
+		}
+	}
+	return selected, nil
+}
+
+// pipelineState - the state of the pipeline, ENABLED, DISABLED or RELEASED
+func pipelineState(pipeline *CodeStreamPipeline) string {
+	if pipeline.State != "" {
+		return strings.ToUpper(pipeline.State)
+	}
+	if pipeline.Enabled {
+		return "ENABLED"
+	}
+	return "DISABLED"
+}
+
+// variableReferencePattern - matches ${var.name} in task inputs
+var variableReferencePattern = regexp.MustCompile(`\$\{var\.(.*?)\}`)
+
+// pipelineSubstitution - a reference rewritten in a pipeline YAML, at the dotted path of the value
+type pipelineSubstitution struct {
+	Path string
+	From string
+	To   string
+}
+
+func (s pipelineSubstitution) String() string {
+	return s.Path + ": " + s.From + " -> " + s.To
+}
+
+// readPipelineMapping - read a mapping file of endpoint, variable and pipeline names
+func readPipelineMapping(mappingPath string) (*CodeStreamPipelineMapping, error) {
+	var mapping CodeStreamPipelineMapping
+	mappingBytes, err := ioutil.ReadFile(mappingPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(mappingBytes, &mapping); err != nil {
+		return nil, fmt.Errorf("%s: %v", mappingPath, err)
+	}
+	return &mapping, nil
+}
+
+// rewritePipelineYaml - set the name and project of an exported pipeline (when not empty) and rename
+// the endpoints, variables and nested pipelines it references using the mapping. Key order is kept.
+func rewritePipelineYaml(yamlBytes []byte, name string, project string, mapping *CodeStreamPipelineMapping) ([]byte, []pipelineSubstitution, error) {
+	var pipeline yaml.MapSlice
+	if err := yaml.Unmarshal(yamlBytes, &pipeline); err != nil {
+		return nil, nil, err
+	}
+	var substitutions []pipelineSubstitution
+	for _, field := range [][2]string{{"name", name}, {"project", project}} {
+		key, to := field[0], field[1]
+		if to == "" {
+			continue
+		}
+		found := false
+		for i, item := range pipeline {
+			if item.Key == key {
+				found = true
+				if from := fmt.Sprint(item.Value); from != to {
+					substitutions = append(substitutions, pipelineSubstitution{Path: key, From: from, To: to})
+					pipeline[i].Value = to
+				}
+			}
+		}
+		if !found {
+			pipeline = append(pipeline, yaml.MapItem{Key: key, Value: to})
+		}
+	}
+	if mapping == nil {
+		mapping = &CodeStreamPipelineMapping{}
+	}
+	for i, item := range pipeline {
+		pipeline[i].Value = mapping.rewrite(item.Value, []string{fmt.Sprint(item.Key)}, &substitutions)
+	}
+	sort.SliceStable(substitutions, func(i, j int) bool { return substitutions[i].Path < substitutions[j].Path })
+	rewritten, err := yaml.Marshal(pipeline)
+	return rewritten, substitutions, err
+}
+
+// rewrite - rename the references in a value of the pipeline found at path
+func (m *CodeStreamPipelineMapping) rewrite(value interface{}, path []string, substitutions *[]pipelineSubstitution) interface{} {
+	switch typed := value.(type) {
+	case yaml.MapSlice:
+		for i, item := range typed {
+			typed[i].Value = m.rewrite(item.Value, append(path, fmt.Sprint(item.Key)), substitutions)
+		}
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = m.rewrite(item, append(path, fmt.Sprint(i)), substitutions)
+		}
+	case string:
+		location := strings.Join(path, ".")
+		var names map[string]string
+		switch {
+		case matchPath(path, "workspace", "endpoint"), matchPath(path, "stages", "*", "tasks", "*", "endpoints", "*"):
+			names = m.Endpoints
+		case matchPath(path, "stages", "*", "tasks", "*", "input", "pipeline"):
+			names = m.Pipelines
+		}
+		if to, ok := names[typed]; ok && to != typed {
+			*substitutions = append(*substitutions, pipelineSubstitution{Path: location, From: typed, To: to})
+			return to
+		}
+		return variableReferencePattern.ReplaceAllStringFunc(typed, func(reference string) string {
+			from := variableReferencePattern.FindStringSubmatch(reference)[1]
+			to, ok := m.Variables[from]
+			if !ok || to == from {
+				return reference
+			}
+			*substitutions = append(*substitutions, pipelineSubstitution{Path: location, From: reference, To: "${var." + to + "}"})
+			return "${var." + to + "}"
+		})
+	}
+	return value
+}
+
+// matchPath - true if the path matches the pattern, where * matches any one key
+func matchPath(path []string, pattern ...string) bool {
+	if len(path) != len(pattern) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != "*" && pattern[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pipelineTaskNode - a task of a pipeline, in a stage
+type pipelineTaskNode struct {
+	Name       string
+	Task       CodeStreamPipelineTask
+	Definition interface{}
+	Missing    bool
+}
+
+// pipelineStageNode - a stage of a pipeline, with its tasks in groups that run one after the other.
+// The tasks within a group run in parallel.
+type pipelineStageNode struct {
+	Name    string
+	Groups  [][]pipelineTaskNode
+	Missing bool
+}
+
+// pipelineStages - the stages of a pipeline in stage order, with their tasks in task order.
+// Parallel tasks are listed in taskOrder as a comma separated entry.
+func pipelineStages(pipeline *CodeStreamPipeline) []pipelineStageNode {
+	var stages []pipelineStageNode
+	for _, stageName := range pipeline.StageOrder {
+		node := pipelineStageNode{Name: stageName}
+		s, ok := pipeline.Stages[stageName]
+		if !ok {
+			node.Missing = true
+			stages = append(stages, node)
+			continue
+		}
+		stage := CodeStreamPipelineStage{}
+		mapstructure.Decode(s, &stage)
+		for _, entry := range stage.TaskOrder {
+			var group []pipelineTaskNode
+			for _, taskName := range strings.Split(entry, ",") {
+				taskName = strings.TrimSpace(taskName)
+				if taskName == "" {
+					continue
+				}
+				task := pipelineTaskNode{Name: taskName}
+				if t, ok := stage.Tasks[taskName]; ok {
+					mapstructure.Decode(t, &task.Task)
+					task.Definition = t
+				} else {
+					task.Missing = true
+				}
+				group = append(group, task)
+			}
+			if len(group) > 0 {
+				node.Groups = append(node.Groups, group)
+			}
+		}
+		stages = append(stages, node)
+	}
+	return stages
+}
+
+// details - the type, endpoints, referenced pipeline, precondition and failure handling of a task
+func (t pipelineTaskNode) details() []string {
+	if t.Missing {
+		return []string{"(not defined)"}
+	}
+	var details []string
+	if len(t.Task.Endpoints) > 0 {
+		var endpoints []string
+		for key, endpoint := range t.Task.Endpoints {
+			endpoints = append(endpoints, key+"="+endpoint)
+		}
+		sort.Strings(endpoints)
+		details = append(details, "endpoints: "+strings.Join(endpoints, ", "))
+	}
+	if t.Task.Type == "Pipeline" && t.Task.Input.Pipeline != "" {
+		details = append(details, "pipeline: "+t.Task.Input.Pipeline)
+	}
+	if t.Task.PreCondition != "" {
+		details = append(details, "preCondition: "+t.Task.PreCondition)
+	}
+	if t.Task.IgnoreFailure {
+		details = append(details, "ignoreFailure: true")
+	}
+	return details
+}
+
+// pipelineDependencySet - the names of the endpoints, variables, nested pipelines and custom integrations
+// a pipeline uses, sorted
+type pipelineDependencySet struct {
+	Endpoints          []string
+	Variables          []string
+	Pipelines          []string
+	CustomIntegrations []string
+}
+
+// getPipelineDependencies - the endpoints (workspace and task) and the nested pipelines and custom
+// integrations used by a pipeline's tasks, and the ${var.name} variables used anywhere in the pipeline
+func getPipelineDependencies(pipeline *CodeStreamPipeline) pipelineDependencySet {
+	var d pipelineDependencySet
+	var document map[string]interface{}
+	pipelineBytes, _ := json.Marshal(pipeline)
+	json.Unmarshal(pipelineBytes, &document)
+	searchValue(document, "", variableReferencePattern, func(field string, line int, text string) {
+		for _, v := range variableReferencePattern.FindAllStringSubmatch(text, -1) {
+			d.Variables = append(d.Variables, v[1])
+		}
+	})
+	if pipeline.Workspace.Endpoint != "" {
+		d.Endpoints = append(d.Endpoints, pipeline.Workspace.Endpoint)
+	}
+	for _, s := range pipeline.Stages {
+		stage := CodeStreamPipelineStage{}
+		mapstructure.Decode(s, &stage)
+		// Loop through the Stage Tasks
+		for n, t := range stage.Tasks {
+			task := CodeStreamPipelineTask{}
+			mapstructure.Decode(t, &task)
+			for _, e := range task.Endpoints {
+				d.Endpoints = append(d.Endpoints, e)
+			}
+			if task.Type == "Pipeline" {
+				d.Pipelines = append(d.Pipelines, task.Input.Pipeline)
+			}
+			if task.Type == "Custom" {
+				d.CustomIntegrations = append(d.CustomIntegrations, task.Input.Name)
+			}
+			log.Debugln("-- [Task]", n, "(", task.Type, ")")
+		}
+	}
+	for _, names := range []*[]string{&d.Endpoints, &d.Variables, &d.Pipelines, &d.CustomIntegrations} {
+		*names = removeDuplicateStrings(*names)
+		sort.Strings(*names)
+	}
+	return d
+}
+
+// dependentPipeline - a pipeline that uses a variable or endpoint
+type dependentPipeline struct {
+	Pipeline *CodeStreamPipeline
+	Kind     string
+	Name     string
+}
+
+// findDependentPipelines - the pipelines in the project that use any of the named variables or endpoints
+// (kind "variable" or "endpoint")
+func findDependentPipelines(project string, kind string, names []string) ([]dependentPipeline, error) {
+	var dependents []dependentPipeline
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var filters []string
+	if project != "" {
+		filters = append(filters, "(project eq '"+project+"')")
+	}
+	pipelines, err := getAllPipelines(filters)
+	if err != nil {
+		return nil, err
+	}
+	for _, pipeline := range pipelines {
+		d := getPipelineDependencies(pipeline)
+		used := d.Endpoints
+		if kind == "variable" {
+			used = d.Variables
+		}
+		for _, name := range names {
+			for _, u := range used {
+				if u == name {
+					dependents = append(dependents, dependentPipeline{Pipeline: pipeline, Kind: kind, Name: name})
+				}
+			}
+		}
+	}
+	return dependents, nil
+}
+
+// scriptIncludeKey - the marker that replaces a task script extracted to a file, naming the file
+const scriptIncludeKey = "$include"
+
+// extractPipelineScripts - move the scripts of the pipeline's tasks (the input script of SSH and PowerShell
+// tasks, the steps of CI tasks) to files in dir, replacing them with a $include marker. CI steps are written
+// one per line, so steps that span several lines are left in the YAML. Returns the file names written.
+func extractPipelineScripts(yamlBytes []byte, dir string) ([]byte, []string, error) {
+	var pipeline yaml.MapSlice
+	if err := yaml.Unmarshal(yamlBytes, &pipeline); err != nil {
+		return nil, nil, err
+	}
+	var files []string
+	used := make(map[string]bool)
+	stages, _ := mapValue(pipeline, "stages").(yaml.MapSlice)
+	for _, stage := range stages {
+		stageMap, _ := stage.Value.(yaml.MapSlice)
+		tasks, _ := mapValue(stageMap, "tasks").(yaml.MapSlice)
+		for _, task := range tasks {
+			taskMap, _ := task.Value.(yaml.MapSlice)
+			input, _ := mapValue(taskMap, "input").(yaml.MapSlice)
+			extension := ".sh"
+			if strings.EqualFold(fmt.Sprint(mapValue(taskMap, "type")), "POWERSHELL") {
+				extension = ".ps1"
+			}
+			for i, item := range input {
+				script, ok := taskScript(fmt.Sprint(item.Key), item.Value)
+				if !ok {
+					continue
+				}
+				baseName := sanitize.PathName(fmt.Sprint(mapValue(pipeline, "name"))) + "." + sanitize.PathName(fmt.Sprint(stage.Key)) + "." + sanitize.PathName(fmt.Sprint(task.Key))
+				fileName := baseName + extension
+				for n := 2; used[fileName]; n++ {
+					fileName = fmt.Sprintf("%s-%d%s", baseName, n, extension)
+				}
+				used[fileName] = true
+				if err := ioutil.WriteFile(filepath.Join(dir, fileName), []byte(script+"\n"), 0644); err != nil {
+					return nil, nil, err
+				}
+				input[i].Value = yaml.MapSlice{{Key: scriptIncludeKey, Value: fileName}}
+				files = append(files, fileName)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return yamlBytes, nil, nil
+	}
+	extracted, err := yaml.Marshal(pipeline)
+	return extracted, files, err
+}
+
+// taskScript - the script in a task input, if the input is a script or CI steps that can be extracted
+func taskScript(key string, value interface{}) (string, bool) {
+	switch key {
+	case "script":
+		script, ok := value.(string)
+		return script, ok && script != ""
+	case "steps":
+		steps, ok := value.([]interface{})
+		if !ok || len(steps) == 0 {
+			return "", false
+		}
+		var lines []string
+		for _, step := range steps {
+			line, ok := step.(string)
+			if !ok || strings.Contains(line, "\n") {
+				return "", false
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n"), true
+	}
+	return "", false
+}
+
+// scriptIncludePath - resolves a $include marker relative to dir. The file must be inside dir, so that
+// importing someone else's pipeline YAML cannot send any other local file to the server.
+func scriptIncludePath(dir, include string) (string, error) {
+	outside := errors.New(scriptIncludeKey + " " + include + " is outside the directory of the pipeline YAML")
+	if filepath.IsAbs(include) {
+		return "", outside
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	includePath, err := filepath.EvalSymlinks(filepath.Join(root, include))
+	if err != nil {
+		return "", err
+	}
+	relPath, err := filepath.Rel(root, includePath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", outside
+	}
+	return includePath, nil
+}
+
+// inlinePipelineScripts - replace the $include markers in a pipeline with the contents of the files they
+// name, which must be inside dir. A file included as CI steps has one step per line.
+func inlinePipelineScripts(yamlBytes []byte, dir string) ([]byte, error) {
+	if !bytes.Contains(yamlBytes, []byte(scriptIncludeKey)) {
+		return yamlBytes, nil
+	}
+	var pipeline yaml.MapSlice
+	if err := yaml.Unmarshal(yamlBytes, &pipeline); err != nil {
+		return nil, err
+	}
+	var inline func(key string, value interface{}) (interface{}, error)
+	inline = func(key string, value interface{}) (interface{}, error) {
+		switch typed := value.(type) {
+		case yaml.MapSlice:
+			if len(typed) == 1 && typed[0].Key == scriptIncludeKey {
+				includePath, err := scriptIncludePath(dir, fmt.Sprint(typed[0].Value))
+				if err != nil {
+					return nil, err
+				}
+				scriptBytes, err := ioutil.ReadFile(includePath)
+				if err != nil {
+					return nil, err
+				}
+				script := strings.TrimSuffix(string(scriptBytes), "\n")
+				if key != "steps" {
+					return script, nil
+				}
+				var steps []interface{}
+				for _, step := range strings.Split(script, "\n") {
+					steps = append(steps, step)
+				}
+				return steps, nil
+			}
+			for i, item := range typed {
+				v, err := inline(fmt.Sprint(item.Key), item.Value)
+				if err != nil {
+					return nil, err
+				}
+				typed[i].Value = v
+			}
+		case []interface{}:
+			for i, item := range typed {
+				v, err := inline(key, item)
+				if err != nil {
+					return nil, err
+				}
+				typed[i] = v
+			}
+		}
+		return value, nil
+	}
+	if _, err := inline("", pipeline); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(pipeline)
+}
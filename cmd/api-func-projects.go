@@ -5,17 +5,19 @@ SPDX-License-Identifier: BSD-2-Clause
 package cmd
 
 import (
-	"crypto/tls"
 	"strings"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/mitchellh/mapstructure"
 	log "github.com/sirupsen/logrus"
 )
 
 func getProject(id, name string) ([]*CodeStreamProject, error) {
 	var projects []*CodeStreamProject
-	client := resty.New()
+	var qParams = make(map[string]string)
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
 
 	var filters []string
 	if id != "" {
@@ -28,12 +30,12 @@ func getProject(id, name string) ([]*CodeStreamProject, error) {
 		qParams["$filter"] = "(" + strings.Join(filters, " and ") + ")"
 	}
 
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/json").
 		SetResult(&CodeStreamProjectList{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Get("https://" + targetConfig.server + "/project-service/api/projects")
+		Get(targetConfig.baseURL() + "/project-service/api/projects")
 
 	if queryResponse.IsError() {
 		return nil, queryResponse.Error().(error)
@@ -6,6 +6,7 @@ package cmd
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"io/ioutil"
 	"os"
@@ -17,26 +18,65 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// getTLSConfig - returns the TLS configuration for the current target
+func getTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: ignoreCert,
+		ServerName:         targetConfig.servername,
+	}
+	if targetConfig.cacertfile != "" {
+		caCert, err := ioutil.ReadFile(targetConfig.cacertfile)
+		if err != nil {
+			return nil, err
+		}
+		// Trust the CA bundle in addition to the OS certificate trust
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+		if !rootCAs.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("no PEM certificates found in " + targetConfig.cacertfile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	if targetConfig.clientcert != "" || targetConfig.clientkey != "" {
+		clientCert, err := tls.LoadX509KeyPair(targetConfig.clientcert, targetConfig.clientkey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+	return tlsConfig, nil
+}
+
+// getRestClient - returns a REST client using the TLS and proxy settings of the current target
+func getRestClient() (*resty.Client, error) {
+	tlsConfig, err := getTLSConfig()
+	if err != nil {
+		return nil, errors.New("Unable to configure TLS: " + err.Error())
+	}
+	client := resty.New().SetTLSClientConfig(tlsConfig)
+	if targetConfig.proxy != "" {
+		client.SetProxy(targetConfig.proxy)
+	}
+	return client, nil
+}
+
 func ensureTargetConnection() error {
 	if testAccessToken() { // If the Access Token is OK
 		log.Debugln("Access Token is valid")
 	} else {
-		var refreshTokenError, credentialError error
-		targetConfig.accesstoken, refreshTokenError = authenticateApiToken(targetConfig.server, targetConfig.apitoken) // Test the API Token (refresh_token)
-		if refreshTokenError != nil {                                                                                  // We could not get an access token from the API Token
-			log.Debugln("Refresh Token is invalid")
-			if targetConfig.server == "api.mgmt.cloud.vmware.com" { // If it's vRA Cloud we have no credentials to authenticate
-				return refreshTokenError // Return the token error
-			}
-			targetConfig.apitoken, credentialError = authenticateCredentials(targetConfig.server, targetConfig.username, targetConfig.password, targetConfig.domain)
-			if credentialError != nil {
-				return credentialError // Return the credential error
-			}
-			// Try again, now we have a new access token
-			targetConfig.accesstoken, refreshTokenError = authenticateApiToken(targetConfig.server, targetConfig.apitoken) // Test the API Token (refresh_token)
-			if refreshTokenError != nil {
-				return refreshTokenError
-			}
+		auth, err := getAuthenticator(targetConfig)
+		if err != nil {
+			return err
+		}
+		accessToken, refreshToken, err := auth.authenticate()
+		if err != nil {
+			return err
+		}
+		targetConfig.accesstoken = accessToken
+		if refreshToken != "" {
+			targetConfig.apitoken = refreshToken
 		}
 
 		if viper.ConfigFileUsed() != "" { // If we're using a Config file
@@ -50,13 +90,16 @@ func ensureTargetConnection() error {
 }
 
 // authenticateCredentials - returns the API Refresh Token for vRA On-premises (8.0.1+)
-func authenticateCredentials(server string, username string, password string, domain string) (string, error) {
+func authenticateCredentials(username string, password string, domain string) (string, error) {
 	log.Debugln("Authenticating vRA with Credentials")
 	var authPath string
 	var authBody AuthenticationRequest
 	authBody.Username = username
 	authBody.Password = password
-	client := resty.New()
+	client, err := getRestClient()
+	if err != nil {
+		return "", err
+	}
 
 	if domain == "" {
 		log.Debugln("Basic Auth")
@@ -69,11 +112,11 @@ func authenticateCredentials(server string, username string, password string, do
 		authBody.Domain = domain
 	}
 
-	loginResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	loginResponse, err := client.R().
 		SetBody(authBody).
 		SetResult(&AuthenticationResponse{}).
 		SetError(&AuthenticationError{}).
-		Post("https://" + server + authPath)
+		Post(targetConfig.baseURL() + authPath)
 	if loginResponse.IsError() {
 		log.Debugln("Authentication failed")
 		return "", errors.New(loginResponse.Error().(*AuthenticationError).ServerMessage)
@@ -83,45 +126,51 @@ func authenticateCredentials(server string, username string, password string, do
 }
 
 // authenticateApiToken - get vRA Access token (valid for 8h)
-func authenticateApiToken(server string, token string) (string, error) {
+func authenticateApiToken(token string) (string, error) {
 	log.Debug("Attempting to authenticate the API Refresh Token")
 	var queryResponse *resty.Response
-	var err error
-	client := resty.New()
-	if server == "api.mgmt.cloud.vmware.com" {
+	client, err := getRestClient()
+	if err != nil {
+		return "", err
+	}
+	if targetConfig.isCloud() {
 		// use the cloud Authentication URL
-		queryResponse, err = client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+		queryResponse, err = client.R().
 			SetFormData(map[string]string{"refresh_token": token}).
 			SetResult(&ApiAuthenticationResponse{}).
 			SetError(&ApiAuthenticationError{}).
-			Post("https://console.cloud.vmware.com/csp/gateway/am/api/auth/api-tokens/authorize")
+			Post(targetConfig.cspURL() + "/csp/gateway/am/api/auth/api-tokens/authorize")
 	} else {
 		// use vRA 8 legacy API
-		queryResponse, err = client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+		queryResponse, err = client.R().
 			SetBody(ApiAuthentication{token}).
 			SetResult(&ApiAuthenticationResponse{}).
 			SetError(&ApiAuthenticationError{}).
-			Post("https://" + server + "/iaas/api/login")
+			Post(targetConfig.baseURL() + "/iaas/api/login")
 	}
 	if queryResponse.IsError() {
 		log.Debug("Refresh Token failed")
 		return "", errors.New(queryResponse.Error().(*ApiAuthenticationError).Message)
 	}
 	log.Debug("Refresh Token succeeded")
-	if server == "api.mgmt.cloud.vmware.com" {
+	if targetConfig.isCloud() {
 		return queryResponse.Result().(*ApiAuthenticationResponse).AccessToken, err
 	}
 	return queryResponse.Result().(*ApiAuthenticationResponse).Token, err
 }
 
 func testAccessToken() bool {
-	client := resty.New()
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	client, err := getRestClient()
+	if err != nil {
+		log.Debugln(err)
+		return false
+	}
+	queryResponse, err := client.R().
 		SetHeader("Accept", "application/json").
 		SetAuthToken(targetConfig.accesstoken).
 		SetResult(&UserPreferences{}).
 		SetError(&CodeStreamException{}).
-		Get("https://" + targetConfig.server + "/pipeline/api/user-preferences")
+		Get(targetConfig.baseURL() + "/pipeline/api/user-preferences")
 	if err != nil {
 		log.Warnln(err)
 		return false
@@ -135,76 +184,128 @@ func testAccessToken() bool {
 	return true
 }
 
+// exportYaml - export a pipeline or endpoint to a YAML file, in canonical form with --canonical and
+// with the pipeline's task scripts in separate files with --extractScripts
 func exportYaml(name, project, path, object string) error {
 	var exportPath string
-	var qParams = make(map[string]string)
-	qParams[object] = name
-	qParams["project"] = project
 	if path != "" {
 		exportPath = path
 	} else {
 		exportPath, _ = os.Getwd()
 	}
-	client := resty.New()
-	queryResponse, _ := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	yamlBytes, err := exportYamlBytes(name, project, object)
+	if err != nil {
+		return err
+	}
+	if canonical {
+		if yamlBytes, err = canonicalYaml(yamlBytes); err != nil {
+			return err
+		}
+	}
+	if extractScripts && object == "pipelines" {
+		var files []string
+		if yamlBytes, files, err = extractPipelineScripts(yamlBytes, exportPath); err != nil {
+			return err
+		}
+		for _, f := range files {
+			log.Debugln("Extracted script", filepath.Join(exportPath, f))
+		}
+	}
+	return ioutil.WriteFile(filepath.Join(exportPath, name+".yaml"), yamlBytes, 0644)
+}
+
+// exportYamlBytes - export a pipeline or endpoint as YAML
+func exportYamlBytes(name, project, object string) ([]byte, error) {
+	var qParams = make(map[string]string)
+	qParams[object] = name
+	qParams["project"] = project
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/x-yaml;charset=UTF-8").
 		SetAuthToken(targetConfig.accesstoken).
-		SetOutput(filepath.Join(exportPath, name+".yaml")).
 		SetError(&CodeStreamException{}).
-		Get("https://" + targetConfig.server + "/pipeline/api/export")
+		Get(targetConfig.baseURL() + "/pipeline/api/export")
+	if err != nil {
+		return nil, err
+	}
 	log.Debugln(queryResponse.Request.RawRequest.URL)
 
 	if queryResponse.IsError() {
-		return errors.New(queryResponse.Status())
+		if exception, ok := queryResponse.Error().(*CodeStreamException); ok && exception.Message != "" {
+			return nil, errors.New(exception.Message)
+		}
+		return nil, errors.New(queryResponse.Status())
 	}
-	return nil
+	return queryResponse.Body(), nil
 }
 
-// importYaml import a yaml pipeline or endpoint
-func importYaml(yamlPath, action, project, importType string) error {
-	var pipeline CodeStreamPipelineYaml
+// importYaml import a yaml pipeline or endpoint, rendering it as a template first when values are given.
+// A pipeline's $include markers are replaced with the scripts they name, its references are renamed with
+// the mapping, and the substitutions made are returned.
+func importYaml(yamlPath, action, project, importType string, values map[string]interface{}, mapping *CodeStreamPipelineMapping) ([]pipelineSubstitution, error) {
 	var endpoint CodeStreamEndpointYaml
+	var substitutions []pipelineSubstitution
 
-	qParams["action"] = action
 	yamlBytes, err := ioutil.ReadFile(yamlPath)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if values != nil {
+		if yamlBytes, err = renderTemplate(filepath.Base(yamlPath), yamlBytes, values); err != nil {
+			return nil, err
+		}
 	}
 
-	if project != "" { // If the project flag is set we need to update the project value
-		if importType == "pipeline" {
-			yamlErr := yaml.Unmarshal(yamlBytes, &pipeline)
-			if yamlErr != nil {
-				return yamlErr
-			}
-			pipeline.Project = project
-			yamlBytes, _ = yaml.Marshal(pipeline)
-		} else {
-			yamlErr := yaml.Unmarshal(yamlBytes, &endpoint)
-			if yamlErr != nil {
-				return yamlErr
+	if importType == "pipeline" {
+		if yamlBytes, err = inlinePipelineScripts(yamlBytes, filepath.Dir(yamlPath)); err != nil {
+			return nil, err
+		}
+		if project != "" || mapping != nil { // Update the project value and rename references
+			if yamlBytes, substitutions, err = rewritePipelineYaml(yamlBytes, "", project, mapping); err != nil {
+				return nil, err
 			}
-			endpoint.Project = project
-			yamlBytes, _ = yaml.Marshal(endpoint)
 		}
+	} else if project != "" { // If the project flag is set we need to update the project value
+		yamlErr := yaml.Unmarshal(yamlBytes, &endpoint)
+		if yamlErr != nil {
+			return nil, yamlErr
+		}
+		endpoint.Project = project
+		yamlBytes, _ = yaml.Marshal(endpoint)
 	}
 
+	return substitutions, importYamlBytes(yamlBytes, action)
+}
+
+// importYamlBytes - import a yaml pipeline or endpoint document
+func importYamlBytes(yamlBytes []byte, action string) error {
+	var qParams = make(map[string]string)
+	qParams["action"] = action
 	yamlPayload := string(yamlBytes)
-	client := resty.New()
-	queryResponse, _ := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	client, err := getRestClient()
+	if err != nil {
+		return err
+	}
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Content-Type", "application/x-yaml").
 		SetBody(yamlPayload).
 		SetAuthToken(targetConfig.accesstoken).
 		SetError(&CodeStreamException{}).
-		Post("https://" + targetConfig.server + "/pipeline/api/import")
+		Post(targetConfig.baseURL() + "/pipeline/api/import")
+	if err != nil {
+		return err
+	}
 	log.Debugln(queryResponse.Request.RawRequest.URL)
 	if queryResponse.IsError() {
-		return queryResponse.Error().(error)
+		return errors.New(queryResponse.Error().(*CodeStreamException).Message)
 	}
 	var importResponse CodeStreamPipelineImportResponse
-	if err = yaml.Unmarshal(queryResponse.Body(), &importResponse); err != nil {
+	if err := yaml.Unmarshal(queryResponse.Body(), &importResponse); err != nil {
 		return err
 	}
 
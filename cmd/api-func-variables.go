@@ -6,24 +6,28 @@ package cmd
 
 import (
 	"bytes"
-	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/mitchellh/mapstructure"
 	"gopkg.in/yaml.v2"
 )
 
 func getVariable(id, name, project, exportPath string) ([]*CodeStreamVariableResponse, error) {
 	var arrVariables []*CodeStreamVariableResponse
-	//var qParams = make(map[string]string)
-	client := resty.New()
+	var qParams = make(map[string]string)
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
 
 	// Get by ID
 	if id != "" {
@@ -43,39 +47,53 @@ func getVariable(id, name, project, exportPath string) ([]*CodeStreamVariableRes
 			qParams["$filter"] = "(project eq '" + project + "')"
 		}
 	}
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
-		SetQueryParams(qParams).
-		SetHeader("Accept", "application/json").
-		SetResult(&documentsList{}).
-		SetAuthToken(targetConfig.accesstoken).
-		Get("https://" + targetConfig.server + "/pipeline/api/variables")
-
-	if queryResponse.IsError() {
-		return nil, queryResponse.Error().(error)
-	}
-
-	log.Debugln(queryResponse.Request.URL)
+	// Get every page of variables
+	pageSize := 100
+	qParams["$top"] = fmt.Sprint(pageSize)
+	for {
+		qParams["$skip"] = fmt.Sprint(len(arrVariables))
+		queryResponse, err := client.R().
+			SetQueryParams(qParams).
+			SetHeader("Accept", "application/json").
+			SetResult(&documentsList{}).
+			SetError(&CodeStreamException{}).
+			SetAuthToken(targetConfig.accesstoken).
+			Get(targetConfig.baseURL() + "/pipeline/api/variables")
+		if err != nil {
+			return nil, err
+		}
+		if queryResponse.IsError() {
+			return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
+		}
+		log.Debugln(queryResponse.Request.URL)
 
-	for _, value := range queryResponse.Result().(*documentsList).Documents {
-		c := CodeStreamVariableResponse{}
-		mapstructure.Decode(value, &c)
-		arrVariables = append(arrVariables, &c)
-		if exportPath != "" {
-			exportVariable(c, exportPath)
+		result := queryResponse.Result().(*documentsList)
+		for _, link := range result.Links {
+			c := CodeStreamVariableResponse{}
+			mapstructure.Decode(result.Documents[link], &c)
+			arrVariables = append(arrVariables, &c)
+			if exportPath != "" {
+				exportVariable(c, exportPath)
+			}
+		}
+		if len(result.Links) < pageSize || len(arrVariables) >= result.TotalCount {
+			return arrVariables, nil
 		}
 	}
-	return arrVariables, err
 }
 
 // getVariableByID - get Code Stream Variable by ID
 func getVariableByID(id string) (*CodeStreamVariableResponse, error) {
-	client := resty.New()
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/json").
 		SetResult(&CodeStreamVariableResponse{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Get("https://" + targetConfig.server + "/pipeline/api/variables/" + id)
+		Get(targetConfig.baseURL() + "/pipeline/api/variables/" + id)
 	if queryResponse.IsError() {
 		log.Errorln("GET Variable failed", err)
 	}
@@ -84,8 +102,11 @@ func getVariableByID(id string) (*CodeStreamVariableResponse, error) {
 
 // createVariable - Create a new Code Stream Variable
 func createVariable(name string, description string, variableType string, project string, value string) (*CodeStreamVariableResponse, error) {
-	client := resty.New()
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetBody(
 			CodeStreamVariableRequest{
@@ -100,7 +121,7 @@ func createVariable(name string, description string, variableType string, projec
 		SetResult(&CodeStreamVariableResponse{}).
 		SetError(&CodeStreamException{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Post("https://" + targetConfig.server + "/pipeline/api/variables")
+		Post(targetConfig.baseURL() + "/pipeline/api/variables")
 	if queryResponse.IsError() {
 		return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
 	}
@@ -122,15 +143,18 @@ func updateVariable(id string, name string, description string, typename string,
 	if value != "" {
 		variable.Value = value
 	}
-	client := resty.New()
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetBody(variable).
 		SetHeader("Accept", "application/json").
 		SetResult(&CodeStreamVariableResponse{}).
 		SetError(&CodeStreamException{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Put("https://" + targetConfig.server + "/pipeline/api/variables/" + id)
+		Put(targetConfig.baseURL() + "/pipeline/api/variables/" + id)
 	if queryResponse.IsError() {
 		return nil, errors.New(queryResponse.Error().(*CodeStreamException).Message)
 	}
@@ -139,13 +163,16 @@ func updateVariable(id string, name string, description string, typename string,
 
 // deleteVariable - Delete a Code Stream Variable
 func deleteVariable(id string) (*CodeStreamVariableResponse, error) {
-	client := resty.New()
-	queryResponse, err := client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: ignoreCert}).R().
+	client, err := getRestClient()
+	if err != nil {
+		return nil, err
+	}
+	queryResponse, err := client.R().
 		SetQueryParams(qParams).
 		SetHeader("Accept", "application/json").
 		SetResult(&CodeStreamVariableResponse{}).
 		SetAuthToken(targetConfig.accesstoken).
-		Delete("https://" + targetConfig.server + "/pipeline/api/variables/" + id)
+		Delete(targetConfig.baseURL() + "/pipeline/api/variables/" + id)
 	if queryResponse.IsError() {
 		return nil, queryResponse.Error().(error)
 	}
@@ -180,6 +207,9 @@ func exportVariable(variable interface{}, exportPath string) {
 	// variable will be a CodeStreamVariableResponse, so lets remap to CodeStreamVariableRequest
 	c := CodeStreamVariableRequest{}
 	mapstructure.Decode(variable, &c)
+	if secretPlaceholders && isSecretVariable(c.Type) {
+		c.Value = secretPlaceholder(c.Name)
+	}
 	yaml, err := yaml.Marshal(c)
 	if err != nil {
 		log.Errorln("Unable to export variable ", c.Name)
@@ -200,19 +230,113 @@ func exportVariable(variable interface{}, exportPath string) {
 	file.WriteString("---\n" + string(yaml))
 }
 
+// secretPlaceholderPattern - a variable value that is a placeholder for a secret, e.g. ${secret.deploy-token}
+var secretPlaceholderPattern = regexp.MustCompile(`^\$\{secret\.([^}]+)\}$`)
+
+// secretEnvPattern - the characters replaced with _ in the environment variable a placeholder resolves from
+var secretEnvPattern = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+// isSecretVariable - whether the values of variables of the type are secret
+func isSecretVariable(variableType string) bool {
+	return strings.EqualFold(variableType, "SECRET") || strings.EqualFold(variableType, "RESTRICTED")
+}
+
+// maskVariable - a copy of the variable with the value masked if it is SECRET or RESTRICTED
+func maskVariable(variable *CodeStreamVariableResponse) *CodeStreamVariableResponse {
+	masked := *variable
+	if isSecretVariable(masked.Type) && masked.Value != "" {
+		masked.Value = "********"
+	}
+	return &masked
+}
+
+// secretPlaceholder - the placeholder exported in place of a secret value
+func secretPlaceholder(name string) string {
+	return "${secret." + name + "}"
+}
+
+// secretEnvName - the environment variable a secret placeholder is resolved from, e.g. CS_SECRET_DEPLOY_TOKEN
+func secretEnvName(key string) string {
+	return "CS_SECRET_" + strings.ToUpper(secretEnvPattern.ReplaceAllString(key, "_"))
+}
+
+// resolveSecretPlaceholders - replace the secret placeholders in the variables' values with the values of
+// CS_SECRET_ environment variables, or else of the secrets file (a YAML map of placeholder key to value)
+func resolveSecretPlaceholders(variables []CodeStreamVariableRequest, secretsPath string) error {
+	secrets := make(map[string]string)
+	if secretsPath != "" {
+		secretsBytes, err := ioutil.ReadFile(secretsPath)
+		if err != nil {
+			return err
+		}
+		if err := yaml.UnmarshalStrict(secretsBytes, &secrets); err != nil {
+			return errors.New("Unable to read secrets file " + secretsPath + ": " + err.Error())
+		}
+	}
+	var unresolved []string
+	for i, variable := range variables {
+		match := secretPlaceholderPattern.FindStringSubmatch(variable.Value)
+		if match == nil {
+			continue
+		}
+		if value, ok := os.LookupEnv(secretEnvName(match[1])); ok {
+			variables[i].Value = value
+		} else if value, ok := secrets[match[1]]; ok {
+			variables[i].Value = value
+		} else {
+			unresolved = append(unresolved, match[1]+" ("+secretEnvName(match[1])+")")
+		}
+	}
+	if len(unresolved) > 0 {
+		return errors.New("No value for secret placeholder(s) " + strings.Join(unresolved, ", ") + " - set the environment variable or add it to the --secrets file")
+	}
+	return nil
+}
+
 // importVariables - Import variables from the filePath
-func importVariables(filePath string) []CodeStreamVariableRequest {
+func importVariables(filePath string) ([]CodeStreamVariableRequest, error) {
 	var returnVariables []CodeStreamVariableRequest
 	filename, _ := filepath.Abs(filePath)
 	yamlFile, err := ioutil.ReadFile(filename)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	reader := bytes.NewReader(yamlFile)
 	decoder := yaml.NewDecoder(reader)
-	var request CodeStreamVariableRequest
-	for decoder.Decode(&request) == nil {
-		returnVariables = append(returnVariables, request)
+	for {
+		var request CodeStreamVariableRequest
+		err := decoder.Decode(&request)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("Unable to read " + filePath + ": " + err.Error())
+		}
+		if request != (CodeStreamVariableRequest{}) {
+			returnVariables = append(returnVariables, request)
+		}
 	}
-	return returnVariables
+	return returnVariables, nil
+}
+
+// validateVariable - check a variable read from a file has a name, a project and a valid type
+func validateVariable(variable CodeStreamVariableRequest) error {
+	if variable.Name == "" {
+		return errors.New("a variable has no name")
+	}
+	if variable.Project == "" {
+		return errors.New("variable " + variable.Name + " has no project")
+	}
+	switch variable.Type {
+	case "REGULAR", "SECRET", "RESTRICTED":
+		return nil
+	}
+	return errors.New("variable " + variable.Name + " has type '" + variable.Type + "', it must be one of REGULAR, SECRET, RESTRICTED")
+}
+
+// variableChanged - whether applying the variable would change the existing one. The values of SECRET
+// and RESTRICTED variables cannot be compared, so they are always considered changed.
+func variableChanged(existing *CodeStreamVariableResponse, variable CodeStreamVariableRequest) bool {
+	return existing.Description != variable.Description || existing.Type != variable.Type ||
+		existing.Value != variable.Value || isSecretVariable(variable.Type)
 }
@@ -56,6 +56,29 @@ type ApiAuthenticationError struct {
 	DocumentKind  string `json:"documentKind"`
 }
 
+// DeviceAuthorizationResponse - OAuth device authorization response structure
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// OAuthError - OAuth error response structure
+type OAuthError struct {
+	ErrorCode        string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.ErrorDescription != "" {
+		return e.ErrorCode + ": " + e.ErrorDescription
+	}
+	return e.ErrorCode
+}
+
 // AuthenticationError - Authentication error structure
 type AuthenticationError struct {
 	Timestamp     int64  `json:"timestamp"`
@@ -220,6 +243,7 @@ type CodeStreamPipelineTask struct {
 type CodeStreamCreateExecutionRequest struct {
 	Comments string      `json:"comments"`
 	Input    interface{} `json:"input"`
+	Tags     []string    `json:"tags,omitempty"`
 }
 
 // CodeStreamCreateExecutionResponse - Code Stream Create Execution Response
@@ -231,6 +255,14 @@ type CodeStreamCreateExecutionResponse struct {
 	Tags          []string    `json:"tags"`
 }
 
+// CodeStreamExecutionMatrix - Matrix of inputs used to start a batch of executions. The values
+// are decoded as strings, so numbers such as 1.10 or 007 keep their literal text
+type CodeStreamExecutionMatrix struct {
+	Inputs  map[string]string   `yaml:"inputs"`
+	Matrix  map[string][]string `yaml:"matrix"`
+	Include []map[string]string `yaml:"include"`
+}
+
 // CodeStreamException - Generic exception struct
 type CodeStreamException struct {
 	Timestamp int64  `json:"timestamp"`
@@ -372,3 +404,11 @@ type CodeStreamEndpointYaml struct {
 	Type        string            `yaml:"type"`
 	Properties  map[string]string `yaml:"properties"`
 }
+
+// CodeStreamPipelineMapping - the new names of the endpoints, variables and nested pipelines a pipeline
+// references, keyed by the old name
+type CodeStreamPipelineMapping struct {
+	Endpoints map[string]string `yaml:"endpoints"`
+	Variables map[string]string `yaml:"variables"`
+	Pipelines map[string]string `yaml:"pipelines"`
+}
@@ -0,0 +1,238 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mrz1836/go-sanitize"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var deleteUnused bool
+var includeCustomIntegrations bool
+
+// unusedObject - a variable, endpoint or custom integration that no pipeline uses
+type unusedObject struct {
+	Kind    string
+	ID      string
+	Name    string
+	Project string
+	Yaml    string
+}
+
+// missingPipeline - a nested pipeline that is called by a pipeline but does not exist
+type missingPipeline struct {
+	Name     string
+	CalledBy string
+	Project  string
+}
+
+// findUnusedObjects - the variables and endpoints in the project, and the custom integrations, that no
+// pipeline uses, along with the nested pipelines that are called but do not exist. Custom integrations
+// are shared by every project, so they are checked against the pipelines of all projects.
+func findUnusedObjects(project string) ([]unusedObject, []missingPipeline, error) {
+	var unused, unusedIntegrations []unusedObject
+	var missing []missingPipeline
+	pipelines, err := getAllPipelines(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	used := map[string]map[string]bool{"variable": {}, "endpoint": {}, "custom integration": {}}
+	existing := make(map[string]bool)
+	for _, pipeline := range pipelines {
+		d := getPipelineDependencies(pipeline)
+		for _, ci := range d.CustomIntegrations {
+			used["custom integration"][ci] = true
+		}
+		if pipeline.Project != project {
+			continue
+		}
+		existing[pipeline.Name] = true
+		for _, v := range d.Variables {
+			used["variable"][v] = true
+		}
+		for _, e := range d.Endpoints {
+			used["endpoint"][e] = true
+		}
+	}
+	for _, pipeline := range pipelines {
+		if pipeline.Project != project {
+			continue
+		}
+		for _, nested := range getPipelineDependencies(pipeline).Pipelines {
+			if !existing[nested] {
+				missing = append(missing, missingPipeline{Name: nested, CalledBy: pipeline.Name, Project: project})
+			}
+		}
+	}
+
+	customIntegrations, err := getCustomIntegration("", "")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, ci := range customIntegrations {
+		if !used["custom integration"][ci.Name] {
+			unusedIntegrations = append(unusedIntegrations, unusedObject{Kind: "custom integration", ID: ci.ID, Name: ci.Name, Yaml: ci.Yaml})
+		}
+	}
+	variables, err := getVariable("", "", project, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range variables {
+		if !used["variable"][v.Name] {
+			unused = append(unused, unusedObject{Kind: "variable", ID: v.ID, Name: v.Name, Project: v.Project})
+		}
+	}
+	endpoints, err := getEndpoint("", "", project, "", "")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, e := range endpoints {
+		if !used["endpoint"][e.Name] {
+			unused = append(unused, unusedObject{Kind: "endpoint", ID: e.ID, Name: e.Name, Project: e.Project})
+		}
+	}
+	return append(unused, unusedIntegrations...), missing, nil
+}
+
+// backupUnusedObjects - export the unused objects to the backup directory, in the layout used by
+// get pipeline --exportDependencies
+func backupUnusedObjects(unused []unusedObject, backupPath string) error {
+	for _, dir := range []string{backupPath, filepath.Join(backupPath, "endpoints"), filepath.Join(backupPath, "custom-integrations")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	for _, o := range unused {
+		var err error
+		switch o.Kind {
+		case "variable":
+			var variable *CodeStreamVariableResponse
+			if variable, err = getVariableByID(o.ID); err == nil {
+				exportVariable(variable, backupPath)
+			}
+		case "endpoint":
+			err = exportYaml(o.Name, o.Project, filepath.Join(backupPath, "endpoints"), "endpoints")
+		case "custom integration":
+			err = ioutil.WriteFile(filepath.Join(backupPath, "custom-integrations", sanitize.PathName(o.Name)+".yaml"), []byte(o.Yaml), 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to back up %s %s: %v", o.Kind, o.Name, err)
+		}
+	}
+	return nil
+}
+
+// deleteUnusedObject - delete an unused variable, endpoint or custom integration
+func deleteUnusedObject(o unusedObject) error {
+	var err error
+	switch o.Kind {
+	case "variable":
+		_, err = deleteVariable(o.ID)
+	case "endpoint":
+		_, err = deleteEndpoint(o.ID)
+	case "custom integration":
+		_, err = deleteCustomIntegration(o.ID)
+	}
+	return err
+}
+
+// auditUnusedCmd represents the audit unused command
+var auditUnusedCmd = &cobra.Command{
+	Use:   "unused",
+	Short: "List the variables, endpoints and custom integrations no Pipeline uses",
+	Long: `List the variables and endpoints in a project, and the custom integrations, that are not used by
+any Pipeline, along with nested Pipelines that are called but no longer exist. Custom integrations are
+shared by all projects, so they are only listed if no Pipeline in any project uses them, and are only
+deleted with --include-custom-integrations.
+
+# List unused objects
+cs-cli audit unused --project "Field Demo"
+# Back up the unused objects, then delete them (prompts for confirmation)
+cs-cli audit unused --project "Field Demo" --exportPath backup/ --delete
+# Delete the unused custom integrations too
+cs-cli audit unused --project "Field Demo" --delete --include-custom-integrations`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if project == "" {
+			log.Fatalln("--project is required")
+		}
+		if err := ensureTargetConnection(); err != nil {
+			log.Fatalln(err)
+		}
+		unused, missing, err := findUnusedObjects(project)
+		if err != nil {
+			log.Fatalln("Unable to audit", project+":", err)
+		}
+		for _, m := range missing {
+			log.Warnln("Pipeline", m.CalledBy, "calls the nested Pipeline", m.Name, "which does not exist in", m.Project)
+		}
+		if len(unused) == 0 {
+			log.Infoln("No unused objects found in", project)
+			return
+		}
+		var rows [][]string
+		for _, o := range unused {
+			rows = append(rows, []string{o.Kind, o.Name, o.Project, o.ID})
+		}
+		PrintRows("table", []string{"Kind", "Name", "Project", "Id"}, rows)
+		log.Infoln(len(unused), "unused objects found")
+
+		if exportPath != "" {
+			if err := backupUnusedObjects(unused, exportPath); err != nil {
+				log.Fatalln(err)
+			}
+			log.Infoln("Backed up", len(unused), "unused objects to", exportPath)
+		}
+		if !deleteUnused {
+			return
+		}
+		var toDelete []unusedObject
+		var descriptions []string
+		for _, o := range unused {
+			if o.Kind == "custom integration" && !includeCustomIntegrations {
+				continue
+			}
+			toDelete = append(toDelete, o)
+			descriptions = append(descriptions, o.Kind+" "+o.Name)
+		}
+		if skipped := len(unused) - len(toDelete); skipped > 0 {
+			log.Infoln(skipped, "unused custom integrations are shared by all projects and will not be deleted, use --include-custom-integrations to delete them")
+		}
+		if len(toDelete) == 0 {
+			return
+		}
+		if !askForConfirmation("This will delete " + fmt.Sprint(len(toDelete)) + " unused objects (" + strings.Join(descriptions, ", ") + "), are you sure?") {
+			log.Fatalln("user declined")
+		}
+		var deleted int
+		for _, o := range toDelete {
+			if err := deleteUnusedObject(o); err != nil {
+				log.Warnln("Unable to delete", o.Kind, o.Name+":", err)
+				continue
+			}
+			deleted++
+			log.Infoln("Deleted", o.Kind, o.Name)
+		}
+		log.Infoln(deleted, "of", len(toDelete), "unused objects deleted")
+		if deleted < len(toDelete) {
+			log.Fatalln(len(toDelete)-deleted, "unused objects could not be deleted")
+		}
+	},
+}
+
+func init() {
+	auditCmd.AddCommand(auditUnusedCmd)
+	auditUnusedCmd.Flags().StringVarP(&project, "project", "p", "", "Project to audit")
+	auditUnusedCmd.Flags().StringVarP(&exportPath, "exportPath", "", "", "Back up the unused objects to this directory")
+	auditUnusedCmd.Flags().BoolVarP(&deleteUnused, "delete", "", false, "Delete the unused objects, after confirmation")
+	auditUnusedCmd.Flags().BoolVarP(&includeCustomIntegrations, "include-custom-integrations", "", false, "Also delete the unused custom integrations, which are shared by all projects")
+}
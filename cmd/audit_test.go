@@ -0,0 +1,79 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware/code-stream-cli/internal/fakeserver"
+)
+
+func TestAuditUnused(t *testing.T) {
+	server := newTestServer(t)
+	server.AddProject("Production")
+	build := testPipeline("Build-App")
+	tasks := build["stages"].(map[string]interface{})["Build"].(map[string]interface{})["tasks"].(map[string]interface{})
+	tasks["Notify"] = map[string]interface{}{"type": "Custom", "input": map[string]interface{}{"name": "Slack"}}
+	tasks["Test"] = map[string]interface{}{"type": "Pipeline", "input": map[string]interface{}{"pipeline": "Run-Tests"}}
+	server.Add(fakeserver.Pipelines, build)
+	other := testPipeline("Other-App")
+	other["project"] = "Production"
+	other["stages"].(map[string]interface{})["Build"].(map[string]interface{})["tasks"].(map[string]interface{})["Notify"] = map[string]interface{}{"type": "Custom", "input": map[string]interface{}{"name": "Teams"}}
+	server.Add(fakeserver.Pipelines, other)
+
+	server.Add(fakeserver.Variables, map[string]interface{}{"name": "deploy-token", "project": testProject, "type": "SECRET", "value": "s3cret"})
+	server.Add(fakeserver.Variables, map[string]interface{}{"name": "old-token", "project": testProject, "type": "REGULAR", "value": "old"})
+	server.Add(fakeserver.Variables, map[string]interface{}{"name": "prod-only", "project": "Production", "type": "REGULAR", "value": "p"})
+	server.Add(fakeserver.Endpoints, map[string]interface{}{"name": "Docker-Host", "project": testProject, "type": "agent"})
+	server.Add(fakeserver.Endpoints, map[string]interface{}{"name": "Old-Host", "project": testProject, "type": "ssh", "kind": "ENDPOINT"})
+	server.Add(fakeserver.CustomIntegrations, map[string]interface{}{"name": "Slack", "yaml": "name: Slack\n"})
+	server.Add(fakeserver.CustomIntegrations, map[string]interface{}{"name": "Teams", "yaml": "name: Teams\n"})
+	server.Add(fakeserver.CustomIntegrations, map[string]interface{}{"name": "Legacy", "yaml": "name: Legacy\n"})
+
+	result := runCommand(t, "", "audit", "unused", "--project", testProject)
+	expectOutput(t, result.stdout, "old-token", "Old-Host", "Legacy")
+	expectOutput(t, result.logs, "3 unused objects found", "Pipeline Build-App calls the nested Pipeline Run-Tests which does not exist in "+testProject)
+	for _, used := range []string{"deploy-token", "Docker-Host", "Slack", "Teams", "prod-only"} {
+		if strings.Contains(result.stdout, used) {
+			t.Errorf("%s is used, or in another project, but was listed", used)
+		}
+	}
+
+	backup := t.TempDir()
+	result = runCommand(t, "n\n", "audit", "unused", "--project", testProject, "--exportPath", backup, "--delete")
+	expectOutput(t, result.logs, "Backed up 3 unused objects", "user declined")
+	if len(server.List(fakeserver.Variables)) != 3 {
+		t.Fatal("objects were deleted although the prompt was declined")
+	}
+	for file, content := range map[string]string{"variables.yaml": "name: old-token", "endpoints/Old-Host.yaml": "name: Old-Host", "custom-integrations/Legacy.yaml": "name: Legacy"} {
+		backedUp, err := os.ReadFile(filepath.Join(backup, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectOutput(t, string(backedUp), content)
+	}
+
+	result = runCommand(t, "y\n", "audit", "unused", "--project", testProject, "--delete")
+	expectOutput(t, result.logs, "1 unused custom integrations are shared by all projects and will not be deleted",
+		"This will delete 2 unused objects (variable old-token, endpoint Old-Host)", "Deleted variable old-token", "Deleted endpoint Old-Host", "2 of 2 unused objects deleted")
+	if server.Find(fakeserver.Variables, "old-token", testProject) != nil || server.Find(fakeserver.Endpoints, "Old-Host", testProject) != nil {
+		t.Error("the unused objects were not deleted")
+	}
+	if len(server.List(fakeserver.CustomIntegrations)) != 3 {
+		t.Error("a custom integration was deleted without --include-custom-integrations")
+	}
+
+	result = runCommand(t, "y\n", "audit", "unused", "--project", testProject, "--delete", "--include-custom-integrations")
+	expectOutput(t, result.logs, "This will delete 1 unused objects (custom integration Legacy)", "Deleted custom integration Legacy", "1 of 1 unused objects deleted")
+	if len(server.List(fakeserver.CustomIntegrations)) != 2 {
+		t.Error("the unused custom integration was not deleted")
+	}
+
+	result = runCommand(t, "", "audit", "unused", "--project", testProject)
+	expectOutput(t, result.logs, "No unused objects found in "+testProject)
+}
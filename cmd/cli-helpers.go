@@ -7,17 +7,25 @@ package cmd
 import (
 	"archive/zip"
 	"bufio"
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/olekukonko/tablewriter"
 	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
 )
 
 // PrettyPrint prints interfaces
@@ -48,6 +56,143 @@ func PrintTable(objects []interface{}, headers []string) {
 	table.Render()
 }
 
+// outputFormats - formats accepted by --output
+var outputFormats = []string{"table", "csv", "json", "markdown"}
+
+// validateOutputFormat - returns an error if the format is not one of outputFormats
+func validateOutputFormat(format string) error {
+	for _, f := range outputFormats {
+		if strings.EqualFold(format, f) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q, expected one of %s", format, strings.Join(outputFormats, ", "))
+}
+
+// PrintRows prints rows as a table, CSV or a Markdown table. JSON output is left to the caller.
+func PrintRows(format string, headers []string, rows [][]string) error {
+	switch strings.ToLower(format) {
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write(headers)
+		w.WriteAll(rows)
+		return w.Error()
+	case "markdown":
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader(headers)
+		table.SetAutoFormatHeaders(false)
+		table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+		table.SetAutoWrapText(false)
+		table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+		table.SetCenterSeparator("|")
+		table.AppendBulk(rows)
+		table.Render()
+	default:
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader(headers)
+		table.AppendBulk(rows)
+		table.Render()
+	}
+	return nil
+}
+
+var relativeTimePattern = regexp.MustCompile(`^(\d+)([smhdw])$`)
+
+// parseTimeFlag - parses a point in time given as an age relative to now (30d, 2w, 12h, 90m, 1h30m)
+// or as an absolute date (2006-01-02) or RFC3339 timestamp
+func parseTimeFlag(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, errors.New("no time given")
+	}
+	if match := relativeTimePattern.FindStringSubmatch(value); match != nil {
+		n, _ := strconv.Atoi(match[1])
+		units := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+		return now.Add(-time.Duration(n) * units[match[2]]), nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use an age such as 30d, 12h or 90m, a date (2006-01-02) or an RFC3339 timestamp", value)
+}
+
+// formatDuration - a human friendly duration, rounded to a precision that suits its length
+func formatDuration(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "-"
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Hour:
+		return d.Round(time.Second).String()
+	default:
+		return d.Round(time.Minute).String()
+	}
+}
+
+// formatRelativeTime - how long before now t was, e.g. "5m ago", or the date if over a month ago
+func formatRelativeTime(t time.Time, now time.Time) string {
+	if t.IsZero() || t.Unix() <= 0 {
+		return "-"
+	}
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	case d < 30*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// isTerminal - true if the file is an interactive terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// readTemplateValues - read the values for a template from a YAML file
+func readTemplateValues(valuesPath string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	valuesBytes, err := ioutil.ReadFile(valuesPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(valuesBytes, &values); err != nil {
+		return nil, fmt.Errorf("%s: %v", valuesPath, err)
+	}
+	return values, nil
+}
+
+// renderTemplate - render a YAML template, with the values available as {{ .Values.name }}.
+// Every placeholder has to resolve, and the result has to be valid YAML.
+func renderTemplate(name string, templateBytes []byte, values map[string]interface{}) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(templateBytes))
+	if err != nil {
+		return nil, err
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, map[string]interface{}{"Values": values}); err != nil {
+		return nil, err
+	}
+	var document interface{}
+	if err := yaml.Unmarshal(rendered.Bytes(), &document); err != nil {
+		return nil, fmt.Errorf("%s did not render valid YAML: %v", name, err)
+	}
+	return rendered.Bytes(), nil
+}
+
 func getYamlFilePaths(importPath string) []string {
 	var yamlFiles []string
 	// Read importPath
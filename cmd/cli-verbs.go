@@ -67,6 +67,94 @@ to quickly create a Cobra application.`,
 	Run:  func(cmd *cobra.Command, args []string) {},
 }
 
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Report on resources",
+	Long: `Summarise resources over time. For example:
+
+	cs-cli report executions --project "Field Demo" --since 30d`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  func(cmd *cobra.Command, args []string) {},
+}
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Clean up old resources",
+	Long: `Delete old resources according to a retention policy. For example:
+
+	cs-cli prune executions --project "Field Demo" --keep-last 20 --older-than 14d --dry-run`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  func(cmd *cobra.Command, args []string) {},
+}
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export resources to files",
+	Long: `Export resources to files for archiving. For example:
+
+	cs-cli export executions --project "Field Demo" --since 90d --out archive/`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  func(cmd *cobra.Command, args []string) {},
+}
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch resources change",
+	Long: `Refresh a view of resources on an interval. For example:
+
+	cs-cli watch executions --project "Field Demo" --interval 10s --bell`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  func(cmd *cobra.Command, args []string) {},
+}
+
+// copyCmd represents the copy command
+var copyCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "Copy resources",
+	Long: `Copy a resource under a new name, project or target. For example:
+
+	cs-cli copy pipeline --name "Golden Pipeline" --project "Field Demo" --to-name "Service A"`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  func(cmd *cobra.Command, args []string) {},
+}
+
+// describeCmd represents the describe command
+var describeCmd = &cobra.Command{
+	Use:   "describe",
+	Short: "Describe resources",
+	Long: `Show the structure of a resource. For example:
+
+	cs-cli describe pipeline --name "Build-App" --output mermaid`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  func(cmd *cobra.Command, args []string) {},
+}
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Audit resources",
+	Long: `Check resources for problems. For example:
+
+	cs-cli audit unused --project "Field Demo"`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  func(cmd *cobra.Command, args []string) {},
+}
+
+// applyCmd represents the apply command
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply resources from a file",
+	Long: `Create or update resources so they match a file. For example:
+
+	cs-cli apply variables -f variables.yaml --prune`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  func(cmd *cobra.Command, args []string) {},
+}
+
 // configCmd represents the config command
 var configCmd = &cobra.Command{
 	Use:   "config",
@@ -160,6 +248,14 @@ func init() {
 	rootCmd.AddCommand(updateCmd)
 	rootCmd.AddCommand(createCmd)
 	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(copyCmd)
+	rootCmd.AddCommand(describeCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(applyCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(completionCmd)
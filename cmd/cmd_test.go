@@ -0,0 +1,190 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	homedir "github.com/mitchellh/go-homedir"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/vmware/code-stream-cli/internal/fakeserver"
+)
+
+const (
+	testUsername = "test-user"
+	testPassword = "VMware1!"
+	testProject  = "Field Demo"
+)
+
+// commandResult - the output of a cs-cli invocation
+type commandResult struct {
+	stdout string
+	logs   string
+	err    error
+	fatal  bool
+}
+
+// fatalExit is raised instead of exiting when a command calls log.Fatal
+type fatalExit struct{}
+
+func init() {
+	homedir.DisableCache = true
+}
+
+// newTestServer - starts a fake Code Stream API with one project, and points
+// cs-cli at it using ENV variables
+func newTestServer(t *testing.T) *fakeserver.Server {
+	t.Helper()
+	server := fakeserver.New(testUsername, testPassword)
+	server.AddProject(testProject)
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CS_SERVER", httpServer.Listener.Addr().String())
+	t.Setenv("CS_USERNAME", testUsername)
+	t.Setenv("CS_PASSWORD", testPassword)
+	return server
+}
+
+// runCommand - runs cs-cli with the arguments over plain HTTP, feeding stdin to any prompts
+func runCommand(t *testing.T, stdin string, args ...string) commandResult {
+	t.Helper()
+	return runCommandWithArgs(t, stdin, append([]string{"--insecure-http"}, args...)...)
+}
+
+// runCommandWithArgs - runs cs-cli with exactly the arguments given
+func runCommandWithArgs(t *testing.T, stdin string, args ...string) (result commandResult) {
+	t.Helper()
+	resetCommandState(rootCmd)
+
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(stdinWriter, stdin)
+	stdinWriter.Close()
+
+	originalStdout, originalStdin := os.Stdout, os.Stdin
+	os.Stdout, os.Stdin = stdoutWriter, stdinReader
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	log.StandardLogger().ExitFunc = func(int) { panic(fatalExit{}) }
+
+	captured := make(chan string)
+	go func() {
+		var b bytes.Buffer
+		io.Copy(&b, stdoutReader)
+		captured <- b.String()
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(fatalExit); !ok {
+				panic(r)
+			}
+			result.fatal = true
+		}
+		stdoutWriter.Close()
+		os.Stdout, os.Stdin = originalStdout, originalStdin
+		log.SetOutput(os.Stderr)
+		log.StandardLogger().ExitFunc = nil
+		result.stdout = <-captured
+		result.logs = logs.String()
+		if testing.Verbose() {
+			fmt.Fprintf(os.Stderr, "$ cs-cli %s\n%s%s", strings.Join(args, " "), result.stdout, result.logs)
+		}
+	}()
+
+	rootCmd.SetArgs(args)
+	result.err = rootCmd.Execute()
+	return result
+}
+
+// resetCommandState - cobra keeps flag values between executions, so put every
+// flag back to its default along with the other package state
+func resetCommandState(cmd *cobra.Command) {
+	resetFlags := func(flag *pflag.Flag) {
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			slice.Replace([]string{})
+		} else {
+			flag.Value.Set(flag.DefValue)
+		}
+		flag.Changed = false
+	}
+	cmd.Flags().VisitAll(resetFlags)
+	cmd.PersistentFlags().VisitAll(resetFlags)
+	for _, child := range cmd.Commands() {
+		resetCommandState(child)
+	}
+	if cmd == rootCmd {
+		viper.Reset()
+		targetConfig = config{}
+		currentTargetName = ""
+	}
+}
+
+// expectOutput - fails the test unless the output contains every string
+func expectOutput(t *testing.T, output string, expected ...string) {
+	t.Helper()
+	for _, e := range expected {
+		if !strings.Contains(output, e) {
+			t.Errorf("expected output to contain %q, got:\n%s", e, output)
+		}
+	}
+}
+
+// writeTestFile - writes a file in a temporary directory and returns its path
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := t.TempDir() + string(os.PathSeparator) + name
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testPipeline - a pipeline document with one SSH task referencing an endpoint and a variable
+func testPipeline(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"project":     testProject,
+		"kind":        "PIPELINE",
+		"name":        name,
+		"description": "Test pipeline " + name,
+		"enabled":     true,
+		"state":       "ENABLED",
+		"concurrency": 10,
+		"input":       map[string]interface{}{"environment": "dev", "version": ""},
+		"workspace":   map[string]interface{}{"endpoint": "Docker-Host", "image": "alpine"},
+		"stageOrder":  []interface{}{"Build"},
+		"stages": map[string]interface{}{
+			"Build": map[string]interface{}{
+				"taskOrder": []interface{}{"Deploy"},
+				"tasks": map[string]interface{}{
+					"Deploy": map[string]interface{}{
+						"type":      "SSH",
+						"endpoints": map[string]interface{}{"agent": "SSH-Host"},
+						"input": map[string]interface{}{
+							"script": "deploy --token ${var.deploy-token} --env ${input.environment}",
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -5,7 +5,9 @@ SPDX-License-Identifier: BSD-2-Clause
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
@@ -22,9 +24,10 @@ var currentTargetCmd = &cobra.Command{
 Examples:
 	# Display the current-target
 	cs-cli config current-target
+	# Display the target selected by --target or CS_TARGET
+	cs-cli config current-target --target vra8-test-ga
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		var currentTargetName = viper.GetString("currentTargetName")
 		if currentTargetName != "" {
 			fmt.Println(currentTargetName)
 		}
@@ -80,12 +83,26 @@ Examples:
 }
 
 var (
-	newTargetName string
-	newServer     string
-	newUsername   string
-	newPassword   string
-	newDomain     string
-	newAPIToken   string
+	newTargetName   string
+	newServer       string
+	newUsername     string
+	newPassword     string
+	newDomain       string
+	newAPIToken     string
+	newProject      string
+	newCACertFile   string
+	newClientCert   string
+	newClientKey    string
+	newServerName   string
+	newProxy        string
+	newType         string
+	newRegion       string
+	newAuthURL      string
+	newAPIURL       string
+	newAuthMethod   string
+	newClientID     string
+	newClientSecret string
+	newAccessToken  string
 )
 
 // setTargetCmd represents the set-target command
@@ -97,7 +114,33 @@ var setTargetCmd = &cobra.Command{
 Examples:
 	cs-cli config set-target --name vra-test-ga --server vra8-test-ga.cmbu.local --username test-user --password VMware1! --domain cmbu.local
 	cs-cli config set-target --name vrac-org --server api.mgmt.cloud.vmware.com --apitoken JhbGciOiJSUzI1NiIsImtpZCI6IjEzNjY3NDcwMTA2Mzk2MTUxNDk0In0
+	# vRealize Automation Cloud in a specific region
+	cs-cli config set-target --name vrac-de --type cloud --region de --apitoken JhbGciOiJSUzI1NiIsImtpZCI6IjEzNjY3NDcwMTA2Mzk2MTUxNDk0In0
+	# vRealize Automation Cloud with explicit API and token exchange URLs
+	cs-cli config set-target --name vrac-gov --type cloud --apiUrl https://api.mgmt.example.vmware.com --authUrl https://console.example.vmware.com --apitoken JhbGciOiJSUzI1NiIsImtpZCI6IjEzNjY3NDcwMTA2Mzk2MTUxNDk0In0
+	# Service account using OAuth client credentials
+	cs-cli config set-target --name vrac-ci --server api.mgmt.cloud.vmware.com --auth clientcredentials --clientId ci-app --clientSecret s3cr3t
+	# Interactive browser (device) login for SSO users
+	cs-cli config set-target --name vrac-sso --server api.mgmt.cloud.vmware.com --auth device --clientId cs-cli-app
+	# Pre-issued access token only, e.g. from a secrets manager (or set CS_ACCESSTOKEN)
+	cs-cli config set-target --name vrac-token --server api.mgmt.cloud.vmware.com --auth token --accessToken eyJhbGciOiJSUzI1NiJ9
+	# Set a default project, used when --project is omitted
+	cs-cli config set-target --name vra-test-ga --project "Field Demo"
+	# Trust an internal CA and authenticate with a client certificate
+	cs-cli config set-target --name vra-test-ga --caCertFile /etc/ssl/corp-ca.pem --clientCert client.pem --clientKey client-key.pem
+	# Connect through an HTTPS proxy
+	cs-cli config set-target --name vra-test-ga --proxy http://proxy.cmbu.local:3128
 `, Args: func(cmd *cobra.Command, args []string) error {
+		switch newType {
+		case "", targetTypeOnPrem, targetTypeCloud:
+		default:
+			return errors.New("--type is not valid, must be " + targetTypeOnPrem + " or " + targetTypeCloud)
+		}
+		if newAuthMethod != "" {
+			if _, err := getAuthenticator(config{authmethod: newAuthMethod}); err != nil {
+				return err
+			}
+		}
 		// if apiToken != "" && server != "" && username == "" && password == "" {
 		// 	return nil
 		// } else if apiToken == "" && server != "" && username != "" && password != "" {
@@ -115,6 +158,44 @@ Examples:
 		fmt.Println("Use `cs-cli config use-target --name " + newTargetName + "` to use this target")
 		if newServer != "" {
 			viper.Set("target."+newTargetName+".server", newServer)
+			// Detect vRA Cloud or on-premises from the server name, unless the target already has a type,
+			// e.g. a sovereign or regional cloud whose host is not recognised
+			if newType == "" && viper.GetString("target."+newTargetName+".type") == "" {
+				detectedType, detectedRegion := detectTargetType(newServer)
+				fmt.Println("Detected target type", detectedType, detectedRegion)
+				viper.Set("target."+newTargetName+".type", detectedType)
+				if detectedType == targetTypeOnPrem { // Drop any cloud settings left from an earlier server
+					for _, key := range []string{"region", "authurl", "apiurl"} {
+						viper.Set("target."+newTargetName+"."+key, "")
+					}
+				} else if newRegion == "" {
+					viper.Set("target."+newTargetName+".region", detectedRegion)
+				}
+			}
+		}
+		if newType != "" {
+			viper.Set("target."+newTargetName+".type", newType)
+		}
+		if newRegion != "" {
+			viper.Set("target."+newTargetName+".region", newRegion)
+		}
+		if newAuthURL != "" {
+			viper.Set("target."+newTargetName+".authurl", newAuthURL)
+		}
+		if newAPIURL != "" {
+			viper.Set("target."+newTargetName+".apiurl", newAPIURL)
+		}
+		if newAuthMethod != "" {
+			viper.Set("target."+newTargetName+".auth", newAuthMethod)
+		}
+		if newClientID != "" {
+			viper.Set("target."+newTargetName+".clientid", newClientID)
+		}
+		if newClientSecret != "" {
+			viper.Set("target."+newTargetName+".clientsecret", newClientSecret)
+		}
+		if newAccessToken != "" {
+			viper.Set("target."+newTargetName+".accesstoken", newAccessToken)
 		}
 		if newUsername != "" {
 			viper.Set("target."+newTargetName+".username", newUsername)
@@ -128,6 +209,24 @@ Examples:
 		if newAPIToken != "" {
 			viper.Set("target."+newTargetName+".apitoken", newAPIToken)
 		}
+		if newProject != "" {
+			viper.Set("target."+newTargetName+".project", newProject)
+		}
+		if newCACertFile != "" {
+			viper.Set("target."+newTargetName+".cacertfile", newCACertFile)
+		}
+		if newClientCert != "" {
+			viper.Set("target."+newTargetName+".clientcert", newClientCert)
+		}
+		if newClientKey != "" {
+			viper.Set("target."+newTargetName+".clientkey", newClientKey)
+		}
+		if newServerName != "" {
+			viper.Set("target."+newTargetName+".servername", newServerName)
+		}
+		if newProxy != "" {
+			viper.Set("target."+newTargetName+".proxy", newProxy)
+		}
 		viper.SetConfigType("yaml")
 		err := viper.SafeWriteConfig()
 		if err != nil {
@@ -175,6 +274,20 @@ func init() {
 	setTargetCmd.Flags().StringVarP(&newPassword, "password", "p", "", "Password to authenticate")
 	setTargetCmd.Flags().StringVarP(&newDomain, "domain", "d", "", "Domain to authenticate (not required for System Domain)")
 	setTargetCmd.Flags().StringVarP(&newAPIToken, "apitoken", "a", "", "API token for vRealize Automation Cloud")
+	setTargetCmd.Flags().StringVarP(&newProject, "project", "", "", "Default project, used when --project is not specified")
+	setTargetCmd.Flags().StringVarP(&newCACertFile, "caCertFile", "", "", "PEM CA bundle used to verify the server certificate")
+	setTargetCmd.Flags().StringVarP(&newClientCert, "clientCert", "", "", "PEM client certificate for mutual TLS")
+	setTargetCmd.Flags().StringVarP(&newClientKey, "clientKey", "", "", "PEM client private key for mutual TLS")
+	setTargetCmd.Flags().StringVarP(&newServerName, "serverName", "", "", "Override the server name (SNI) used to verify the server certificate")
+	setTargetCmd.Flags().StringVarP(&newProxy, "proxy", "", "", "HTTP(S) proxy URL, e.g. http://proxy.cmbu.local:3128")
+	setTargetCmd.Flags().StringVarP(&newType, "type", "", "", "Target type (onprem|cloud), detected from --server when the target has no type")
+	setTargetCmd.Flags().StringVarP(&newRegion, "region", "", "", "vRealize Automation Cloud region (e.g. us, de, uk, au)")
+	setTargetCmd.Flags().StringVarP(&newAuthURL, "authUrl", "", "", "Cloud Services Platform URL used to exchange API tokens (default "+defaultCSPURL+")")
+	setTargetCmd.Flags().StringVarP(&newAPIURL, "apiUrl", "", "", "API base URL, overrides https://<server>")
+	setTargetCmd.Flags().StringVarP(&newAuthMethod, "auth", "", "", "Authentication method ("+strings.Join(authMethods, "|")+"), defaults to apitoken for vRA Cloud and password for vRA On-premises")
+	setTargetCmd.Flags().StringVarP(&newClientID, "clientId", "", "", "OAuth client ID for clientcredentials and device authentication")
+	setTargetCmd.Flags().StringVarP(&newClientSecret, "clientSecret", "", "", "OAuth client secret for clientcredentials authentication")
+	setTargetCmd.Flags().StringVarP(&newAccessToken, "accessToken", "", "", "Pre-issued access token for token authentication")
 	setTargetCmd.MarkFlagRequired("name")
 	// delete-target
 	// configCmd.AddCommand(deleteTargetCmd)
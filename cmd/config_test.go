@@ -0,0 +1,254 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/code-stream-cli/internal/fakeserver"
+	"gopkg.in/yaml.v2"
+)
+
+// useConfigFile - switches from ENV variables to a config file in the test HOME
+func useConfigFile(t *testing.T) string {
+	t.Helper()
+	server := os.Getenv("CS_SERVER")
+	for _, env := range []string{"CS_SERVER", "CS_USERNAME", "CS_PASSWORD"} {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+	return server
+}
+
+func TestConfigTargets(t *testing.T) {
+	server := newTestServer(t)
+	server.AddProject("Production")
+	address := useConfigFile(t)
+
+	runCommand(t, "", "config", "set-target", "--name", "lab", "--server", address, "--username", testUsername, "--password", testPassword, "--project", "Production")
+	runCommand(t, "", "config", "set-target", "--name", "broken", "--server", address, "--username", testUsername, "--password", "wrong")
+	config, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".cs-cli.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOutput(t, string(config), "lab:", "broken:", "project: Production")
+
+	runCommand(t, "", "config", "use-target", "--name", "lab")
+	result := runCommand(t, "", "config", "current-target")
+	expectOutput(t, result.stdout, "lab")
+
+	result = runCommand(t, "", "get", "project", "--name", "Production")
+	expectOutput(t, result.stdout, "Production")
+
+	result = runCommand(t, "", "--target", "broken", "get", "project")
+	if !result.fatal {
+		t.Error("--target did not switch to the target with the wrong password")
+	}
+	t.Setenv("CS_TARGET", "broken")
+	result = runCommand(t, "", "get", "project")
+	if !result.fatal {
+		t.Error("CS_TARGET did not switch to the target with the wrong password")
+	}
+}
+
+// readConfigTarget - returns the settings of a target in the config file in the test HOME
+func readConfigTarget(t *testing.T, name string) map[string]string {
+	t.Helper()
+	configBytes, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".cs-cli.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var configuration struct {
+		Target map[string]map[string]string `yaml:"target"`
+	}
+	if err := yaml.Unmarshal(configBytes, &configuration); err != nil {
+		t.Fatal(err)
+	}
+	return configuration.Target[name]
+}
+
+func TestSetTargetType(t *testing.T) {
+	newTestServer(t)
+	useConfigFile(t)
+
+	// A stored type is kept when the server is changed
+	runCommand(t, "", "config", "set-target", "--name", "sov", "--type", "cloud", "--apiUrl", "https://api.cloud.example.gov", "--authUrl", "https://console.cloud.example.gov")
+	result := runCommand(t, "", "config", "set-target", "--name", "sov", "--server", "api.cloud.example.gov")
+	if strings.Contains(result.stdout, "Detected target type") {
+		t.Error("the type of the target was detected again")
+	}
+	if target := readConfigTarget(t, "sov"); target["type"] != "cloud" || target["apiurl"] != "https://api.cloud.example.gov" || target["authurl"] != "https://console.cloud.example.gov" {
+		t.Errorf("the cloud settings were changed: %v", target)
+	}
+
+	// A target without a type is detected, dropping cloud settings when it is on-premises
+	runCommand(t, "", "config", "set-target", "--name", "eu", "--server", "eu.api.mgmt.cloud.vmware.com")
+	if target := readConfigTarget(t, "eu"); target["type"] != "cloud" || target["region"] != "eu" {
+		t.Errorf("the cloud region was not detected: %v", target)
+	}
+	runCommand(t, "", "config", "set-target", "--name", "legacy", "--region", "eu", "--apiUrl", "https://eu.api.mgmt.cloud.vmware.com")
+	result = runCommand(t, "", "config", "set-target", "--name", "legacy", "--server", "vra.corp.local")
+	expectOutput(t, result.stdout, "Detected target type onprem")
+	if target := readConfigTarget(t, "legacy"); target["type"] != "onprem" || target["region"] != "" || target["apiurl"] != "" || target["authurl"] != "" {
+		t.Errorf("cloud settings were left on an on-premises target: %v", target)
+	}
+}
+
+func TestDetectTargetType(t *testing.T) {
+	for _, test := range []struct {
+		server, targetType, region string
+	}{
+		{"api.mgmt.cloud.vmware.com", targetTypeCloud, "us"},
+		{"API.MGMT.CLOUD.VMWARE.COM", targetTypeCloud, "us"},
+		{"eu.api.mgmt.cloud.vmware.com", targetTypeCloud, "eu"},
+		{"vra.corp.local", targetTypeOnPrem, ""},
+		{"api.mgmt.cloud.vmware.com.example.com", targetTypeOnPrem, ""},
+		{"", targetTypeOnPrem, ""},
+	} {
+		targetType, region := detectTargetType(test.server)
+		if targetType != test.targetType || region != test.region {
+			t.Errorf("detectTargetType(%q) = %s %s, want %s %s", test.server, targetType, region, test.targetType, test.region)
+		}
+	}
+	for region, server := range map[string]string{"": "api.mgmt.cloud.vmware.com", "us": "api.mgmt.cloud.vmware.com", "EU": "eu.api.mgmt.cloud.vmware.com", "jp": "jp.api.mgmt.cloud.vmware.com"} {
+		if got := cloudServer(region); got != server {
+			t.Errorf("cloudServer(%q) = %s, want %s", region, got, server)
+		}
+	}
+}
+
+func TestTargetURLs(t *testing.T) {
+	defer func(insecure bool) { insecureHTTP = insecure }(insecureHTTP)
+	for _, test := range []struct {
+		name     string
+		target   config
+		insecure bool
+		baseURL  string
+		cspURL   string
+	}{
+		{"on-premises", config{targettype: targetTypeOnPrem, server: "vra.corp.local"}, false, "https://vra.corp.local", "https://vra.corp.local"},
+		{"on-premises over HTTP", config{targettype: targetTypeOnPrem, server: "localhost:8080"}, true, "http://localhost:8080", "http://localhost:8080"},
+		{"cloud", config{targettype: targetTypeCloud, server: "api.mgmt.cloud.vmware.com"}, false, "https://api.mgmt.cloud.vmware.com", defaultCSPURL},
+		{"cloud region", config{targettype: targetTypeCloud, region: "eu"}, false, "https://eu.api.mgmt.cloud.vmware.com", defaultCSPURL},
+		{"cloud without region", config{targettype: targetTypeCloud}, false, "https://api.mgmt.cloud.vmware.com", defaultCSPURL},
+		{"explicit URLs", config{targettype: targetTypeCloud, region: "eu", apiurl: "https://api.cloud.example.gov/", authurl: "https://console.cloud.example.gov/"}, true, "https://api.cloud.example.gov", "https://console.cloud.example.gov"},
+		{"on-premises auth URL", config{targettype: targetTypeOnPrem, server: "vra.corp.local", authurl: "https://sso.corp.local"}, false, "https://vra.corp.local", "https://sso.corp.local"},
+	} {
+		insecureHTTP = test.insecure
+		if got := test.target.baseURL(); got != test.baseURL {
+			t.Errorf("%s: baseURL() = %s, want %s", test.name, got, test.baseURL)
+		}
+		if got := test.target.cspURL(); got != test.cspURL {
+			t.Errorf("%s: cspURL() = %s, want %s", test.name, got, test.cspURL)
+		}
+	}
+}
+
+func TestDefaultProject(t *testing.T) {
+	server := newTestServer(t)
+	server.AddProject("Production")
+	server.Add(fakeserver.Variables, map[string]interface{}{"name": "in-default", "project": "Production", "type": "REGULAR", "value": "a"})
+	server.Add(fakeserver.Variables, map[string]interface{}{"name": "in-other", "project": testProject, "type": "REGULAR", "value": "b"})
+	t.Setenv("CS_PROJECT", "Production")
+
+	result := runCommand(t, "", "get", "variable")
+	expectOutput(t, result.stdout, "in-default")
+	if strings.Contains(result.stdout, "in-other") {
+		t.Error("the default project was not applied")
+	}
+	result = runCommand(t, "", "get", "variable", "--project", testProject)
+	expectOutput(t, result.stdout, "in-other")
+
+	server.Add(fakeserver.Pipelines, testPipeline("Build-App"))
+	production := testPipeline("Deploy-App")
+	production["project"] = "Production"
+	server.Add(fakeserver.Pipelines, production)
+	result = runCommand(t, "", "search", "--query", "SSH-Host", "--output", "csv")
+	expectOutput(t, result.stdout, "Deploy-App,Production")
+	if strings.Contains(result.stdout, "Build-App") {
+		t.Error("search did not use the default project")
+	}
+
+	// The default never selects the objects of a destructive or bulk command
+	result = runCommand(t, "y\n", "audit", "unused", "--delete")
+	if !result.fatal || server.Find(fakeserver.Variables, "in-default", "Production") == nil {
+		t.Error("audit unused used the default project")
+	}
+	expectOutput(t, result.logs, "--project is required")
+	result = runCommand(t, "", "prune", "executions", "--keep-last", "1")
+	expectOutput(t, result.logs, "--project is required")
+	result = runCommand(t, "", "update", "pipeline", "--state", "DISABLED")
+	expectOutput(t, result.logs, "--state requires --id, --name, --project, --tag or --list")
+}
+
+func TestAccessTokenRefresh(t *testing.T) {
+	server := newTestServer(t)
+	address := useConfigFile(t)
+	runCommand(t, "", "config", "set-target", "--name", "lab", "--server", address, "--username", testUsername, "--password", testPassword)
+	runCommand(t, "", "config", "use-target", "--name", "lab")
+
+	result := runCommand(t, "", "get", "project")
+	expectOutput(t, result.stdout, testProject)
+
+	server.ExpireAccessTokens()
+	result = runCommand(t, "", "get", "project")
+	if result.fatal {
+		t.Fatalf("the access token was not refreshed:\n%s", result.logs)
+	}
+	expectOutput(t, result.stdout, testProject)
+}
+
+func TestTokenOnlyTarget(t *testing.T) {
+	server := newTestServer(t)
+	t.Setenv("CS_AUTH", authToken)
+	t.Setenv("CS_ACCESSTOKEN", server.IssueAccessToken())
+
+	result := runCommand(t, "", "get", "project")
+	expectOutput(t, result.stdout, testProject)
+
+	server.ExpireAccessTokens()
+	result = runCommand(t, "", "get", "project")
+	if !result.fatal {
+		t.Error("an expired token was accepted by a token-only target")
+	}
+}
+
+func TestDeviceLoginWithoutExpiry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/device/authorize") {
+			io.WriteString(w, `{"device_code":"device","user_code":"ABCD","verification_uri":"https://example.com/device","interval":1}`)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error":"authorization_pending"}`)
+	}))
+	defer server.Close()
+	defer func(expiry time.Duration) { defaultDeviceCodeExpiry = expiry }(defaultDeviceCodeExpiry)
+	defaultDeviceCodeExpiry = 1500 * time.Millisecond
+
+	_, _, err := deviceAuthenticator{target: config{clientid: "cs-cli", authurl: server.URL}}.authenticate()
+	if err == nil || !strings.Contains(err.Error(), "device login expired") {
+		t.Errorf("a device login without expires_in did not expire: %v", err)
+	}
+}
+
+func TestInvalidCACertificate(t *testing.T) {
+	newTestServer(t)
+	t.Setenv("CS_CACERTFILE", writeTestFile(t, "ca.pem", "not a certificate"))
+
+	result := runCommand(t, "", "get", "project")
+	expectOutput(t, result.logs, "Unable to configure TLS: no PEM certificates found in")
+	if strings.Contains(result.stdout, testProject) {
+		t.Error("the request was sent with an invalid CA certificate")
+	}
+}
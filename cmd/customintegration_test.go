@@ -0,0 +1,23 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/vmware/code-stream-cli/internal/fakeserver"
+)
+
+func TestGetCustomIntegration(t *testing.T) {
+	server := newTestServer(t)
+	server.Add(fakeserver.CustomIntegrations, map[string]interface{}{"name": "base64Encode", "status": "RELEASED", "description": "Encode a string"})
+	server.Add(fakeserver.CustomIntegrations, map[string]interface{}{"name": "slackNotify", "status": "DRAFT"})
+
+	result := runCommand(t, "", "get", "customintegration")
+	expectOutput(t, result.stdout, "base64Encode", "slackNotify", "RELEASED")
+
+	result = runCommand(t, "", "get", "customintegration", "--name", "base64Encode")
+	expectOutput(t, result.stdout, `"description": "Encode a string"`)
+}
@@ -72,7 +72,7 @@ var createEndpointCmd = &cobra.Command{
 			}
 			for _, yamlFilePath := range yamlFilePaths {
 				yamlFileName := filepath.Base(yamlFilePath)
-				err := importYaml(yamlFilePath, "create", project, "endpoint")
+				_, err := importYaml(yamlFilePath, "create", project, "endpoint", nil, nil)
 				if err != nil {
 					log.Warnln("Failed to import", yamlFilePath, "as Endpoint", err)
 				} else {
@@ -106,7 +106,7 @@ var updateEndpointCmd = &cobra.Command{
 			}
 			for _, yamlFilePath := range yamlFilePaths {
 				yamlFileName := filepath.Base(yamlFilePath)
-				err := importYaml(yamlFilePath, "apply", "", "endpoint")
+				_, err := importYaml(yamlFilePath, "apply", "", "endpoint", nil, nil)
 				if err != nil {
 					log.Warnln("Failed to import", yamlFilePath, "as Endpoint", err)
 				} else {
@@ -134,6 +134,8 @@ cs-cli delete endpoint --project "My Project" --name "Endpoint Name"
 
 # Delete all Endpoints in Project (prompts for confirmation):
 cs-cli delete endpoint --project "My Project"
+
+Pipelines that use the Endpoint(s) are listed and nothing is deleted, unless --force is given.
 	`,
 	Args: func(cmd *cobra.Command, args []string) error {
 		// if id != "" && name != "" {
@@ -149,30 +151,37 @@ cs-cli delete endpoint --project "My Project"
 		if err := ensureTargetConnection(); err != nil {
 			log.Fatalln(err)
 		}
-		if name != "" {
+		if id != "" || name != "" {
 			response, err := getEndpoint(id, name, project, typename, exportPath)
 			if err != nil {
 				log.Fatalln(err)
 			}
-			id = response[0].ID
-		}
-
-		if id != "" {
-
-			response, err := deleteEndpoint(id)
+			if len(response) != 1 {
+				log.Fatalln("Found", len(response), "matching Endpoints - use --project or --id")
+			}
+			checkDependentPipelines(response[0].Project, "endpoint", []string{response[0].Name}, "deleted")
+			response[0], err = deleteEndpoint(response[0].ID)
 			if err != nil {
-				log.Errorln("Unable to delete Endpoint: ", err)
+				log.Fatalln("Unable to delete Endpoint: ", err)
 			}
-			log.Infoln("Endpoint with id " + response.ID + " deleted")
+			log.Infoln("Endpoint with id " + response[0].ID + " deleted")
 		} else if project != "" {
+			endpoints, err := getEndpoint("", "", project, "", "")
+			if err != nil {
+				log.Fatalln(err)
+			}
+			var names []string
+			for _, e := range endpoints {
+				names = append(names, e.Name)
+			}
+			checkDependentPipelines(project, "endpoint", names, "deleted")
 			response, err := deleteEndpointByProject(project)
 			if err != nil {
 				log.Errorln("Unable to delete Endpoint: ", err)
+			} else {
+				log.Infoln(len(response), "Endpoints deleted")
 			}
-			log.Infoln(len(response), "Endpoints deleted")
-
 		}
-
 	},
 }
 
@@ -183,6 +192,7 @@ func init() {
 	getEndpointCmd.Flags().StringVarP(&project, "project", "p", "", "Filter Endpoint by Project")
 	getEndpointCmd.Flags().StringVarP(&typename, "type", "t", "", "Filter Endpoint by Type")
 	getEndpointCmd.Flags().StringVarP(&exportPath, "exportPath", "", "", "Path to export objects - relative or absolute location")
+	getEndpointCmd.Flags().BoolVarP(&canonical, "canonical", "", false, "Export the YAML in canonical form (see cs-cli fmt)")
 	// Create
 	createCmd.AddCommand(createEndpointCmd)
 	createEndpointCmd.Flags().StringVarP(&importPath, "importPath", "c", "", "YAML configuration file to import")
@@ -197,5 +207,6 @@ func init() {
 	deleteEndpointCmd.Flags().StringVarP(&id, "id", "i", "", "ID of the Endpoint to delete")
 	deleteEndpointCmd.Flags().StringVarP(&name, "name", "n", "", "Name of the Endpoint to delete")
 	deleteEndpointCmd.Flags().StringVarP(&project, "project", "p", "", "Delete Endpoints by Project")
+	deleteEndpointCmd.Flags().BoolVarP(&force, "force", "", false, "Delete even if Pipelines use the Endpoint")
 
 }
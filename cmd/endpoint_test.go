@@ -0,0 +1,53 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"testing"
+
+	"github.com/vmware/code-stream-cli/internal/fakeserver"
+)
+
+func TestEndpointLifecycle(t *testing.T) {
+	server := newTestServer(t)
+
+	result := runCommand(t, "", "create", "endpoint", "--importPath", "testdata/endpoint.yaml")
+	expectOutput(t, result.stdout, "Imported endpoint.yaml successfully - Endpoint created.")
+
+	result = runCommand(t, "", "get", "endpoint", "--name", "Git-Endpoint")
+	expectOutput(t, result.stdout, `"type": "git"`, `"branch": "main"`)
+
+	result = runCommand(t, "", "update", "endpoint", "--importPath", "testdata/endpoint.yaml")
+	expectOutput(t, result.stdout, "Imported endpoint.yaml successfully - Endpoint updated.")
+
+	result = runCommand(t, "", "delete", "endpoint", "--name", "Git-Endpoint")
+	expectOutput(t, result.logs, "deleted")
+	if len(server.List(fakeserver.Endpoints)) != 0 {
+		t.Error("endpoint was not deleted")
+	}
+}
+
+func TestDeleteEndpointInUse(t *testing.T) {
+	server := newTestServer(t)
+	server.Add(fakeserver.Pipelines, testPipeline("Build-App"))
+	server.Add(fakeserver.Endpoints, map[string]interface{}{"name": "Docker-Host", "project": testProject, "type": "agent"})
+	server.Add(fakeserver.Endpoints, map[string]interface{}{"name": "Unused-Host", "project": testProject, "type": "ssh"})
+
+	result := runCommand(t, "", "delete", "endpoint", "--name", "Docker-Host")
+	expectOutput(t, result.stdout, "Build-App", "endpoint Docker-Host")
+	expectOutput(t, result.logs, "1 Pipelines use the endpoint(s) to be deleted")
+	if server.Find(fakeserver.Endpoints, "Docker-Host", testProject) == nil {
+		t.Fatal("an endpoint used by a pipeline was deleted without --force")
+	}
+
+	result = runCommand(t, "", "delete", "endpoint", "--name", "Unused-Host")
+	expectOutput(t, result.logs, "deleted")
+
+	result = runCommand(t, "y\n", "delete", "endpoint", "--project", testProject, "--force")
+	expectOutput(t, result.logs, "continuing because of --force", "1 Endpoints deleted")
+	if len(server.List(fakeserver.Endpoints)) != 0 {
+		t.Error("the endpoints were not deleted with --force")
+	}
+}
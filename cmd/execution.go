@@ -7,6 +7,8 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -18,6 +20,34 @@ var nested bool
 var inputs string
 var comments string
 var inputPath string
+var inputPairs []string
+var tags []string
+var matrixPath string
+var concurrency int
+var pollInterval time.Duration
+var matrixTimeout time.Duration
+var since string
+var reportSince string
+var outputFormat string
+var topMessages int
+var keepLast int
+var olderThan string
+var statuses []string
+var dryRun bool
+var outPath string
+var exportSince string
+var until string
+var executedBy string
+var filterTags []string
+var rollback bool
+var pipelineID string
+var odataFilter string
+var sortBy string
+var ascending bool
+var watchInterval time.Duration
+var iterations int
+var bell bool
+var webhookURL string
 
 // getExecutionCmd represents the executions command
 var getExecutionCmd = &cobra.Command{
@@ -28,13 +58,39 @@ var getExecutionCmd = &cobra.Command{
 	  cs-cli get execution --status FAILED
 	Get an execution by ID:
 	  cs-cli get execution --id bb3f6aff-311a-45fe-8081-5845a529068d
+	Get the longest executions of the last week started by a user:
+	  cs-cli get execution --since 7d --executed-by fritz@coke.sqa-horizon.local --sort duration
+	Get executions tagged with a build number, or matching any OData filter:
+	  cs-cli get execution --tag build-1234
+	  cs-cli get execution --filter "index gt 100"
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
+		filter := executionFilter{project: project, status: status, name: name, nested: nested, executedBy: executedBy, tags: filterTags, pipelineID: pipelineID, filter: odataFilter}
+		now := time.Now()
+		if since != "" {
+			var err error
+			if filter.since, err = parseTimeFlag(since, now); err != nil {
+				log.Fatalln("Invalid --since:", err)
+			}
+		}
+		if until != "" {
+			var err error
+			if filter.until, err = parseTimeFlag(until, now); err != nil {
+				log.Fatalln("Invalid --until:", err)
+			}
+		}
+		if cmd.Flags().Changed("rollback") {
+			filter.rollback = &rollback
+		}
+		orderBy, err := executionOrderBy(sortBy, ascending)
+		if err != nil {
+			log.Fatalln(err)
+		}
 		if err := ensureTargetConnection(); err != nil {
 			log.Fatalln(err)
 		}
 
-		response, err := getExecutions(id, project, status, name, nested)
+		response, err := getExecutions(id, filter, orderBy)
 		if err != nil {
 			log.Errorln("Unable to get executions: ", err)
 		}
@@ -47,9 +103,11 @@ var getExecutionCmd = &cobra.Command{
 		} else {
 			// Print result table
 			table := tablewriter.NewWriter(os.Stdout)
-			table.SetHeader([]string{"Id", "Name", "Project", "Status", "Message"})
+			table.SetHeader([]string{"Id", "Name", "Project", "Status", "Requested", "Duration", "Message"})
 			for _, c := range response {
-				table.Append([]string{c.ID, c.Name + "#" + fmt.Sprint(c.Index), c.Project, c.Status, c.StatusMessage})
+				requested := formatRelativeTime(time.Unix(0, c.RequestTimeInMicros*1000), now)
+				duration := formatDuration(time.Duration(c.TotalDurationInMicros) * time.Microsecond)
+				table.Append([]string{c.ID, c.Name + "#" + fmt.Sprint(c.Index), c.Project, c.Status, requested, duration, c.StatusMessage})
 			}
 			table.Render()
 		}
@@ -90,23 +148,406 @@ var delExecutionCmd = &cobra.Command{
 var createExecutionCmd = &cobra.Command{
 	Use:   "execution",
 	Short: "Create an Execution",
-	Long: `Create an Execution with a specific pipeline ID and form payload.
-	
+	Long: `Create an Execution of a pipeline, by ID or by name and project, with a form payload.
+	Inputs are read from --inputPath (JSON or YAML), then --inputs (JSON), then each --input key=value,
+	with later values overriding earlier ones. Inputs are validated against the pipeline input form,
+	and any input not supplied uses the pipeline default.
+	  cs-cli create execution --id 7b3c1f2a-8cb7-4b6d-b4c1-0e6f5f6e8e43 --input environment=prod --input version=1.2.3
+	  cs-cli create execution --id 7b3c1f2a-8cb7-4b6d-b4c1-0e6f5f6e8e43 --inputPath inputs.yaml
+	Tag the execution so it can be found later:
+	  cs-cli create execution --name Build-App --project "Field Demo" --tags build-1234,commit-4f2a9c1
+	Start one execution for each combination of inputs in a matrix file, and wait for them all to finish:
+	  cs-cli create execution --name Deploy-App --project "Field Demo" --matrix matrix.yaml --concurrency 3
+	The matrix file lists values for each input under "matrix", extra combinations under "include",
+	and inputs shared by every execution under "inputs". No more executions run at once than the
+	pipeline concurrency allows. With --timeout, executions still running (or not yet started)
+	when it expires are reported as timed out.
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := ensureTargetConnection(); err != nil {
 			log.Fatalln(err)
 		}
 
-		response, err := createExecution(id, inputs, comments)
+		executionInputs, err := getExecutionInputs(inputPath, inputs, inputPairs)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		pipeline, err := getPipeline(id, name, project)
+		if err != nil {
+			log.Fatalln("Unable to get pipeline: ", err)
+		}
+		if matrixPath != "" {
+			createMatrixExecutions(pipeline, executionInputs)
+			return
+		}
+		executionInputs, err = validateExecutionInputs(pipeline, executionInputs)
 		if err != nil {
-			log.Errorln("Unable to create execution: ", err)
+			log.Fatalln(err)
+		}
+		response, err := createExecution(pipeline.ID, executionInputs, comments, tags)
+		if err != nil {
+			log.Fatalln("Unable to create execution: ", err)
 		}
 		log.Infoln("Execution " + response.ExecutionLink + " created")
 
 	},
 }
 
+// reportExecutionCmd represents the report executions command
+var reportExecutionCmd = &cobra.Command{
+	Use:     "executions",
+	Aliases: []string{"execution"},
+	Short:   "Report execution statistics by Pipeline",
+	Long: `Report the runs, success rate, p50/p95 duration, most frequent failing task and most
+frequent failure messages of each Pipeline, for executions requested since --since.
+	  cs-cli report executions --project "Field Demo" --since 30d
+	  cs-cli report executions --project "Field Demo" --since 2022-01-01 --output markdown
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		return validateOutputFormat(outputFormat)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		sinceTime, err := parseTimeFlag(reportSince, time.Now())
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := ensureTargetConnection(); err != nil {
+			log.Fatalln(err)
+		}
+		filter := executionFilter{project: project, name: name, since: sinceTime}
+		executions, err := getAllExecutions(filter.odata(), "_requestTimeInMicros desc")
+		if err != nil {
+			log.Fatalln("Unable to get executions: ", err)
+		}
+		reports := reportExecutions(executions, topMessages)
+		if strings.EqualFold(outputFormat, "json") {
+			if reports == nil {
+				reports = []*executionReport{}
+			}
+			PrettyPrint(reports)
+			return
+		}
+		if len(reports) == 0 {
+			log.Infoln("No executions found since", sinceTime.Format(time.RFC3339))
+			return
+		}
+		var rows [][]string
+		for _, r := range reports {
+			var topMessage string
+			if len(r.TopStatusMessages) > 0 {
+				topMessage = fmt.Sprintf("%s (%d)", r.TopStatusMessages[0].Value, r.TopStatusMessages[0].Count)
+			}
+			successRate := "-"
+			if r.Completed+r.Failed > 0 {
+				successRate = fmt.Sprintf("%.1f%%", r.SuccessRate)
+			}
+			var topTask string
+			if r.TopFailingTask != "" {
+				topTask = fmt.Sprintf("%s (%d)", r.TopFailingTask, r.TopFailingCount)
+			}
+			rows = append(rows, []string{
+				r.Pipeline,
+				r.Project,
+				fmt.Sprint(r.Runs),
+				successRate,
+				formatDuration(time.Duration(r.P50DurationMicros) * time.Microsecond),
+				formatDuration(time.Duration(r.P95DurationMicros) * time.Microsecond),
+				topTask,
+				topMessage,
+			})
+		}
+		if err := PrintRows(outputFormat, []string{"Pipeline", "Project", "Runs", "Success Rate", "p50", "p95", "Top Failing Task", "Top Status Message"}, rows); err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+// pruneExecutionCmd represents the prune executions command
+var pruneExecutionCmd = &cobra.Command{
+	Use:     "executions",
+	Aliases: []string{"execution"},
+	Short:   "Delete old Executions by retention policy",
+	Long: `Delete the Executions of each Pipeline in a Project that are not among the --keep-last most
+recent runs, were requested before --older-than and have one of the --status values.
+The latest run of each status and unfinished Executions are always kept.
+	  cs-cli prune executions --project "Field Demo" --keep-last 20 --older-than 14d --status COMPLETED,FAILED --dry-run
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if project == "" {
+			log.Fatalln("--project is required")
+		}
+		if keepLast < 1 && olderThan == "" {
+			log.Fatalln("--keep-last and/or --older-than is required")
+		}
+		var olderThanTime time.Time
+		if olderThan != "" {
+			var err error
+			if olderThanTime, err = parseTimeFlag(olderThan, time.Now()); err != nil {
+				log.Fatalln(err)
+			}
+		}
+		if err := ensureTargetConnection(); err != nil {
+			log.Fatalln(err)
+		}
+		filter := executionFilter{project: project, name: name}
+		executions, err := getAllExecutions(filter.odata(), "_requestTimeInMicros desc")
+		if err != nil {
+			log.Fatalln("Unable to get executions: ", err)
+		}
+		plans := planExecutionPrune(executions, keepLast, olderThanTime, statuses)
+		var total int
+		for _, plan := range plans {
+			total += len(plan.Delete)
+		}
+		if total == 0 {
+			log.Infoln("No executions to delete in", project)
+			return
+		}
+		if dryRun {
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetHeader([]string{"Execution", "Status", "Requested", "Id"})
+			for _, plan := range plans {
+				for _, e := range plan.Delete {
+					requested := time.Unix(0, e.RequestTimeInMicros*1000).Format("2006-01-02 15:04:05")
+					table.Append([]string{e.Name + "#" + fmt.Sprint(e.Index), e.Status, requested, e.ID})
+				}
+			}
+			table.Render()
+			log.Infoln("Dry run:", total, "of", len(executions), "executions would be deleted")
+			return
+		}
+		if !askForConfirmation("This will delete " + fmt.Sprint(total) + " of " + fmt.Sprint(len(executions)) + " Executions in " + project + ", are you sure?") {
+			log.Fatalln("user declined")
+		}
+		pruneExecutions(plans, concurrency)
+
+		var deleted, failed int
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Pipeline", "Executions", "Deleted", "Kept", "Errors"})
+		for _, plan := range plans {
+			deleted += plan.Deleted
+			failed += plan.Errors
+			table.Append([]string{plan.Pipeline, fmt.Sprint(plan.Executions), fmt.Sprint(plan.Deleted), fmt.Sprint(plan.Executions - plan.Deleted), fmt.Sprint(plan.Errors)})
+		}
+		table.Render()
+		if failed > 0 {
+			log.Fatalln(deleted, "Executions deleted,", failed, "could not be deleted")
+		}
+		log.Infoln(deleted, "Executions deleted")
+	},
+}
+
+// exportExecutionCmd represents the export executions command
+var exportExecutionCmd = &cobra.Command{
+	Use:     "executions",
+	Aliases: []string{"execution"},
+	Short:   "Export Executions to JSON files",
+	Long: `Export each Execution of a Project requested since --since to a JSON file in --out, including
+its inputs, outputs, stage and task results, workspace logs, executor and comments, and list them in
+index.json. Exports are incremental - running the export again only fetches new Executions, and those
+that had not finished when they were last exported.
+	  cs-cli export executions --project "Field Demo" --since 90d --out archive/
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if project == "" {
+			log.Fatalln("--project is required")
+		}
+		if outPath == "" {
+			log.Fatalln("--out is required")
+		}
+		sinceTime, err := parseTimeFlag(exportSince, time.Now())
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := ensureTargetConnection(); err != nil {
+			log.Fatalln(err)
+		}
+		written, total, err := exportExecutions(project, name, sinceTime, outPath)
+		if err != nil {
+			log.Fatalln("Unable to export executions: ", err)
+		}
+		log.Infoln("Exported", written, "executions to", outPath, "-", total, "executions in the index")
+	},
+}
+
+// watchExecutionCmd represents the watch executions command
+var watchExecutionCmd = &cobra.Command{
+	Use:     "executions",
+	Aliases: []string{"execution"},
+	Short:   "Watch running and recent Executions",
+	Long: `Refresh a view of the running and most recent Executions (--count) on an interval, showing
+the current stage and elapsed time and highlighting status changes. When an execution fails,
+optionally ring the terminal bell and/or post it to a webhook (e.g. a Slack incoming webhook).
+	  cs-cli watch executions --project "Field Demo" --interval 10s --bell
+	  cs-cli watch executions --project "Field Demo" --webhook https://hooks.slack.com/services/T000/B000/XXXX
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureTargetConnection(); err != nil {
+			log.Fatalln(err)
+		}
+		recent := executionFilter{project: project, name: name}
+		running := recent
+		running.filter = unfinishedExecutionsFilter
+		terminal := isTerminal(os.Stdout)
+		var previous map[string]string // execution ID -> status at the last refresh
+		for i := 1; iterations == 0 || i <= iterations; i++ {
+			executions, err := getExecutions("", recent, "_requestTimeInMicros desc")
+			if err == nil {
+				// Executions that are still running, but older than the most recent
+				var unfinished []*CodestreamAPIExecutions
+				if unfinished, err = getExecutions("", running, "_requestTimeInMicros desc"); err == nil {
+					executions = mergeExecutions(executions, unfinished)
+				}
+			}
+			if err == nil {
+				// Executions that were running at the last refresh, and have since finished
+				executions = mergeExecutions(executions, getFinishedExecutions(previous, executions))
+			}
+			if err != nil {
+				log.Errorln("Unable to get executions: ", err)
+			} else {
+				previous = renderExecutionWatch(executions, previous, terminal)
+			}
+			if iterations == 0 || i < iterations {
+				time.Sleep(watchInterval)
+			}
+		}
+	},
+}
+
+// getFinishedExecutions - gets the executions that were running at the last refresh but are
+// missing from the current executions, because they have finished
+func getFinishedExecutions(previous map[string]string, executions []*CodestreamAPIExecutions) []*CodestreamAPIExecutions {
+	listed := make(map[string]bool)
+	for _, e := range executions {
+		listed[e.ID] = true
+	}
+	var finished []*CodestreamAPIExecutions
+	for id, status := range previous {
+		if listed[id] || executionFinished(status) {
+			continue
+		}
+		execution, err := getExecution("/codestream/api/executions/" + id)
+		if err != nil {
+			log.Debugln("Unable to get execution", id, err)
+			continue
+		}
+		finished = append(finished, execution)
+	}
+	return finished
+}
+
+// renderExecutionWatch - prints a refresh of the watched executions, and notifies of executions
+// that have failed since the previous refresh. Returns the status of each execution.
+func renderExecutionWatch(executions []*CodestreamAPIExecutions, previous map[string]string, terminal bool) map[string]string {
+	now := time.Now()
+	current := make(map[string]string)
+	if terminal {
+		fmt.Print("\033[H\033[2J") // Clear the screen
+	}
+	fmt.Println("Executions at", now.Format("15:04:05"), "- refreshing every", watchInterval)
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Execution", "Project", "Status", "Stage", "Elapsed", "Requested"})
+	for _, e := range executions {
+		current[e.ID] = e.Status
+		status := e.Status
+		lastStatus, seen := previous[e.ID]
+		changed := previous != nil && (!seen || lastStatus != e.Status)
+		if changed && seen {
+			status = lastStatus + " -> " + e.Status
+		}
+		elapsed := time.Duration(e.TotalDurationInMicros) * time.Microsecond
+		if !executionFinished(e.Status) {
+			elapsed = now.Sub(time.Unix(0, e.RequestTimeInMicros*1000))
+		}
+		row := []string{e.Name + "#" + fmt.Sprint(e.Index), e.Project, status, executionStage(e), formatDuration(elapsed), formatRelativeTime(time.Unix(0, e.RequestTimeInMicros*1000), now)}
+		if changed {
+			if terminal {
+				for i := range row {
+					row[i] = "\033[1m" + row[i] + "\033[0m"
+				}
+			} else {
+				row[0] = "* " + row[0]
+			}
+		}
+		table.Append(row)
+		if changed && strings.EqualFold(e.Status, "FAILED") {
+			log.Warnln("Execution", e.Name+"#"+fmt.Sprint(e.Index), "failed:", e.StatusMessage)
+			if bell {
+				fmt.Print("\a")
+			}
+			if webhookURL != "" {
+				if err := notifyExecutionFailed(webhookURL, e); err != nil {
+					log.Warnln("Unable to call webhook:", err)
+				}
+			}
+		}
+	}
+	table.Render()
+	return current
+}
+
+// createMatrixExecutions - runs the pipeline for every combination in the matrix file and prints a summary
+func createMatrixExecutions(pipeline *CodeStreamPipeline, executionInputs map[string]interface{}) {
+	combinations, shared, err := getMatrixInputs(matrixPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if shared == nil {
+		shared = make(map[string]interface{})
+	}
+	for key, value := range executionInputs {
+		shared[key] = value
+	}
+	limit := concurrency
+	if pipeline.Concurrency > 0 && limit > pipeline.Concurrency {
+		log.Warnln("Pipeline", pipeline.Name, "allows", pipeline.Concurrency, "concurrent executions, limiting --concurrency to", pipeline.Concurrency)
+		limit = pipeline.Concurrency
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	log.Infoln("Starting", len(combinations), "executions of", pipeline.Name, "-", limit, "at a time")
+	var deadline time.Time
+	if matrixTimeout > 0 {
+		deadline = time.Now().Add(matrixTimeout)
+	}
+	results, err := runExecutionMatrix(pipeline, combinations, shared, comments, tags, limit, pollInterval, deadline)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var failed int
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Execution", "Inputs", "Status", "Duration", "Message"})
+	for _, r := range results {
+		if r.Err == errExecutionTimeout {
+			failed++
+			if r.Execution == nil {
+				table.Append([]string{"", formatInputs(r.Combination), "TIMED OUT", "", "Not started before --timeout"})
+			} else {
+				table.Append([]string{r.Execution.Name + "#" + fmt.Sprint(r.Execution.Index), formatInputs(r.Combination), "TIMED OUT", "", "Still " + r.Execution.Status + " after --timeout"})
+			}
+			continue
+		}
+		if r.Err != nil {
+			failed++
+			table.Append([]string{"", formatInputs(r.Combination), "ERROR", "", r.Err.Error()})
+			continue
+		}
+		if r.Execution.Status != "COMPLETED" {
+			failed++
+		}
+		duration := (time.Duration(r.Execution.TotalDurationInMicros) * time.Microsecond).Round(time.Second)
+		table.Append([]string{r.Execution.Name + "#" + fmt.Sprint(r.Execution.Index), formatInputs(r.Combination), r.Execution.Status, duration.String(), r.Execution.StatusMessage})
+	}
+	table.Render()
+	if failed > 0 {
+		log.Fatalln(failed, "of", len(results), "executions did not complete successfully")
+	}
+	log.Infoln("All", len(results), "executions completed successfully")
+}
+
 func init() {
 	// Get
 	getCmd.AddCommand(getExecutionCmd)
@@ -115,6 +556,15 @@ func init() {
 	getExecutionCmd.Flags().StringVarP(&status, "status", "s", "", "Filter executions by status (Completed|Waiting|Pausing|Paused|Resuming|Running)")
 	getExecutionCmd.Flags().StringVarP(&project, "project", "p", "", "Filter executions by Project")
 	getExecutionCmd.Flags().BoolVarP(&nested, "nested", "", false, "Include nested executions")
+	getExecutionCmd.Flags().StringVarP(&since, "since", "", "", "Filter executions requested since this age (7d, 12h) or date (2006-01-02)")
+	getExecutionCmd.Flags().StringVarP(&until, "until", "", "", "Filter executions requested before this age (7d, 12h) or date (2006-01-02)")
+	getExecutionCmd.Flags().StringVarP(&executedBy, "executed-by", "", "", "Filter executions by the user that started them")
+	getExecutionCmd.Flags().StringSliceVarP(&filterTags, "tag", "", []string{}, "Filter executions by tag (can be repeated, all tags must match)")
+	getExecutionCmd.Flags().BoolVarP(&rollback, "rollback", "", false, "Filter rollback executions (--rollback=false for executions that are not rollbacks)")
+	getExecutionCmd.Flags().StringVarP(&pipelineID, "pipeline-id", "", "", "Filter executions by the ID of the pipeline")
+	getExecutionCmd.Flags().StringVarP(&odataFilter, "filter", "", "", "Filter executions with an OData $filter expression")
+	getExecutionCmd.Flags().StringVarP(&sortBy, "sort", "", "time", "Sort executions by time or duration, newest or longest first")
+	getExecutionCmd.Flags().BoolVarP(&ascending, "ascending", "", false, "Sort oldest or shortest first")
 	// Delete
 	deleteCmd.AddCommand(delExecutionCmd)
 	delExecutionCmd.Flags().StringVarP(&name, "name", "n", "", "Name of the pipeline to delete executions for")
@@ -125,8 +575,45 @@ func init() {
 	// Create
 	createCmd.AddCommand(createExecutionCmd)
 	createExecutionCmd.Flags().StringVarP(&id, "id", "i", "", "ID of the pipeline to execute")
+	createExecutionCmd.Flags().StringVarP(&name, "name", "n", "", "Name of the pipeline to execute")
+	createExecutionCmd.Flags().StringVarP(&project, "project", "p", "", "Project of the pipeline to execute")
 	createExecutionCmd.Flags().StringVarP(&inputs, "inputs", "", "", "JSON form inputs")
-	createExecutionCmd.Flags().StringVarP(&inputPath, "inputPath", "", "", "JSON input file")
+	createExecutionCmd.Flags().StringVarP(&inputPath, "inputPath", "", "", "JSON or YAML input file")
+	createExecutionCmd.Flags().StringArrayVarP(&inputPairs, "input", "", []string{}, "Form input as key=value (can be repeated)")
 	createExecutionCmd.Flags().StringVarP(&comments, "comments", "", "", "Execution comments")
-	createExecutionCmd.MarkFlagRequired("id")
+	createExecutionCmd.Flags().StringVarP(&matrixPath, "matrix", "", "", "YAML file of input combinations to start one execution for each")
+	createExecutionCmd.Flags().IntVarP(&concurrency, "concurrency", "", 5, "Maximum number of matrix executions to run at once")
+	createExecutionCmd.Flags().DurationVarP(&pollInterval, "interval", "", 10*time.Second, "How often to check the status of matrix executions")
+	createExecutionCmd.Flags().DurationVarP(&matrixTimeout, "timeout", "", 0, "How long to wait for the matrix executions to finish, e.g. 2h (default no timeout)")
+	createExecutionCmd.Flags().StringSliceVarP(&tags, "tags", "", []string{}, "Tags to add to the execution (comma separated or repeated)")
+	// Report
+	reportCmd.AddCommand(reportExecutionCmd)
+	reportExecutionCmd.Flags().StringVarP(&project, "project", "p", "", "Report on executions in the Project")
+	reportExecutionCmd.Flags().StringVarP(&name, "name", "n", "", "Report on executions of the Pipeline")
+	reportExecutionCmd.Flags().StringVarP(&reportSince, "since", "", "30d", "Report on executions requested since this age (30d, 12h) or date (2006-01-02)")
+	reportExecutionCmd.Flags().StringVarP(&outputFormat, "output", "o", "table", "Output format (table|csv|json|markdown)")
+	reportExecutionCmd.Flags().IntVarP(&topMessages, "top", "", 3, "Number of most frequent status messages to include in JSON output")
+	// Prune
+	pruneCmd.AddCommand(pruneExecutionCmd)
+	pruneExecutionCmd.Flags().StringVarP(&project, "project", "p", "", "Project to prune executions in")
+	pruneExecutionCmd.Flags().StringVarP(&name, "name", "n", "", "Only prune executions of this Pipeline")
+	pruneExecutionCmd.Flags().IntVarP(&keepLast, "keep-last", "", 0, "Number of most recent executions of each pipeline to keep")
+	pruneExecutionCmd.Flags().StringVarP(&olderThan, "older-than", "", "", "Only delete executions requested before this age (14d, 12h) or date (2006-01-02)")
+	pruneExecutionCmd.Flags().StringSliceVarP(&statuses, "status", "s", []string{}, "Only delete executions with these statuses (e.g. COMPLETED,FAILED)")
+	pruneExecutionCmd.Flags().BoolVarP(&dryRun, "dry-run", "", false, "List the executions that would be deleted without deleting them")
+	pruneExecutionCmd.Flags().IntVarP(&concurrency, "concurrency", "", 5, "Maximum number of executions to delete at once")
+	// Export
+	exportCmd.AddCommand(exportExecutionCmd)
+	exportExecutionCmd.Flags().StringVarP(&project, "project", "p", "", "Project to export executions from")
+	exportExecutionCmd.Flags().StringVarP(&name, "name", "n", "", "Only export executions of this Pipeline")
+	exportExecutionCmd.Flags().StringVarP(&exportSince, "since", "", "90d", "Export executions requested since this age (90d, 12h) or date (2006-01-02)")
+	exportExecutionCmd.Flags().StringVarP(&outPath, "out", "", "", "Directory to export the executions to")
+	// Watch
+	watchCmd.AddCommand(watchExecutionCmd)
+	watchExecutionCmd.Flags().StringVarP(&project, "project", "p", "", "Watch executions in the Project")
+	watchExecutionCmd.Flags().StringVarP(&name, "name", "n", "", "Watch executions of the Pipeline")
+	watchExecutionCmd.Flags().DurationVarP(&watchInterval, "interval", "", 5*time.Second, "How often to refresh")
+	watchExecutionCmd.Flags().IntVarP(&iterations, "iterations", "", 0, "Number of refreshes before exiting (0 to watch until interrupted)")
+	watchExecutionCmd.Flags().BoolVarP(&bell, "bell", "", false, "Ring the terminal bell when an execution fails")
+	watchExecutionCmd.Flags().StringVarP(&webhookURL, "webhook", "", "", "URL to post failed executions to as JSON")
 }
@@ -0,0 +1,524 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/code-stream-cli/internal/fakeserver"
+)
+
+func TestCreateAndGetExecution(t *testing.T) {
+	server := newTestServer(t)
+	id := server.Add(fakeserver.Pipelines, testPipeline("Build-App"))
+
+	result := runCommand(t, "", "create", "execution", "--id", id, "--inputs", `{"environment":"test"}`, "--comments", "From the tests")
+	expectOutput(t, result.logs, "Execution /codestream/api/executions/", "created")
+	executions := server.List(fakeserver.Executions)
+	if len(executions) != 1 {
+		t.Fatalf("expected 1 execution, got %d", len(executions))
+	}
+	execution := executions[0]
+	if execution["comments"] != "From the tests" || execution["input"].(map[string]interface{})["environment"] != "test" {
+		t.Errorf("execution was created with the wrong comments or inputs: %v", execution)
+	}
+
+	result = runCommand(t, "", "get", "execution", "--id", execution["id"].(string))
+	expectOutput(t, result.stdout, `"name": "Build-App"`, `"status": "COMPLETED"`, `"environment": "test"`)
+}
+
+func TestGetExecutionFilters(t *testing.T) {
+	server := newTestServer(t)
+	server.Add(fakeserver.Executions, map[string]interface{}{"name": "Build-App", "project": testProject, "index": 1, "status": "COMPLETED", "statusMessage": "Execution Completed."})
+	server.Add(fakeserver.Executions, map[string]interface{}{"name": "Build-App", "project": testProject, "index": 2, "status": "FAILED", "statusMessage": "Build.Compile: Script execution failed."})
+	server.Add(fakeserver.Executions, map[string]interface{}{"name": "Deploy-App", "project": testProject, "index": 1, "status": "FAILED", "statusMessage": "Deploy.Rollout: Timed out."})
+
+	result := runCommand(t, "", "get", "execution", "--project", testProject)
+	expectOutput(t, result.stdout, "Build-App#1", "Build-App#2", "Deploy-App#1")
+
+	result = runCommand(t, "", "get", "execution", "--status", "failed")
+	expectOutput(t, result.stdout, "Build-App#2", "Deploy-App#1")
+	if strings.Contains(result.stdout, "Build-App#1") {
+		t.Error("--status returned executions with another status")
+	}
+
+	result = runCommand(t, "", "get", "execution", "--name", "Deploy-App")
+	expectOutput(t, result.stdout, `"statusMessage": "Deploy.Rollout: Timed out."`)
+}
+
+func TestDeleteExecution(t *testing.T) {
+	server := newTestServer(t)
+	id := server.Add(fakeserver.Executions, map[string]interface{}{"name": "Build-App", "project": testProject, "index": 1, "status": "COMPLETED"})
+	server.Add(fakeserver.Executions, map[string]interface{}{"name": "Build-App", "project": testProject, "index": 2, "status": "FAILED"})
+	server.Add(fakeserver.Executions, map[string]interface{}{"name": "Build-App", "project": testProject, "index": 3, "status": "FAILED"})
+
+	result := runCommand(t, "", "delete", "execution", "--id", id)
+	expectOutput(t, result.logs, "Execution with id "+id+" deleted")
+
+	result = runCommand(t, "y\n", "delete", "execution", "--project", testProject, "--status", "FAILED")
+	expectOutput(t, result.logs, "2 Executions deleted")
+	if len(server.List(fakeserver.Executions)) != 0 {
+		t.Error("executions were not deleted")
+	}
+}
+
+func TestCreateExecutionInputs(t *testing.T) {
+	server := newTestServer(t)
+	id := server.Add(fakeserver.Pipelines, testPipeline("Build-App"))
+	inputFile := writeTestFile(t, "inputs.yaml", "environment: staging\nversion: 1.2\n")
+
+	runCommand(t, "", "create", "execution", "--id", id, "--inputPath", inputFile, "--input", "environment=prod")
+	runCommand(t, "", "create", "execution", "--id", id, "--input", "version=2.0")
+	executions := server.List(fakeserver.Executions)
+	if len(executions) != 2 {
+		t.Fatalf("expected 2 executions, got %d", len(executions))
+	}
+	if input := executions[0]["input"].(map[string]interface{}); input["environment"] != "prod" || input["version"] != "1.2" {
+		t.Errorf("--input did not override --inputPath: %v", input)
+	}
+	if input := executions[1]["input"].(map[string]interface{}); input["environment"] != "dev" || input["version"] != "2.0" {
+		t.Errorf("the pipeline default was not used: %v", input)
+	}
+
+	result := runCommand(t, "", "create", "execution", "--id", id, "--input", "enviroment=prod")
+	expectOutput(t, result.logs, "unknown input(s) for pipeline Build-App: enviroment (accepted inputs: environment, version)")
+	result = runCommand(t, "", "create", "execution", "--id", id, "--input", "environment")
+	expectOutput(t, result.logs, `invalid input \"environment\", expected key=value`)
+	if len(server.List(fakeserver.Executions)) != 2 {
+		t.Error("an execution was created with invalid inputs")
+	}
+
+	// Numbers keep their literal text
+	inputFile = writeTestFile(t, "inputs.yaml", "environment: 007\nversion: 1.10\n")
+	runCommand(t, "", "create", "execution", "--id", id, "--inputPath", inputFile)
+	runCommand(t, "", "create", "execution", "--id", id, "--inputs", `{"environment": true, "version": 1.10}`)
+	executions = server.List(fakeserver.Executions)
+	if input := executions[2]["input"].(map[string]interface{}); input["environment"] != "007" || input["version"] != "1.10" {
+		t.Errorf("the YAML inputs were changed: %v", input)
+	}
+	if input := executions[3]["input"].(map[string]interface{}); input["environment"] != "true" || input["version"] != "1.10" {
+		t.Errorf("the JSON inputs were changed: %v", input)
+	}
+	result = runCommand(t, "", "create", "execution", "--id", id, "--inputs", `{"version": [1, 2]}`)
+	expectOutput(t, result.logs, "input version must be a string, number or boolean")
+	inputFile = writeTestFile(t, "inputs.yaml", "version:\n  major: 1\n")
+	result = runCommand(t, "", "create", "execution", "--id", id, "--inputPath", inputFile)
+	expectOutput(t, result.logs, "unable to read inputs from "+inputFile)
+	if len(server.List(fakeserver.Executions)) != 4 {
+		t.Error("an execution was created with invalid inputs")
+	}
+}
+
+func TestCreateExecutionErrors(t *testing.T) {
+	defer func(c config, insecure bool) { targetConfig, insecureHTTP = c, insecure }(targetConfig, insecureHTTP)
+	insecureHTTP = true
+	responses := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"comments":"","source":"API"}`)
+	}))
+	defer responses.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	targetConfig = config{targettype: targetTypeOnPrem, server: strings.TrimPrefix(closed.URL, "http://")}
+	if _, err := createExecution("pipeline-id", nil, "", nil); err == nil {
+		t.Error("a request that was never sent created an execution")
+	}
+	targetConfig.server = strings.TrimPrefix(responses.URL, "http://")
+	if _, err := createExecution("pipeline-id", nil, "", nil); err == nil || !strings.Contains(err.Error(), "did not return a link to the execution") {
+		t.Errorf("a response without an execution link was accepted: %v", err)
+	}
+}
+
+func TestCreateExecutionByName(t *testing.T) {
+	server := newTestServer(t)
+	server.AddProject("Production")
+	server.Add(fakeserver.Pipelines, testPipeline("Build-App"))
+	production := testPipeline("Build-App")
+	production["project"] = "Production"
+	productionID := server.Add(fakeserver.Pipelines, production)
+
+	result := runCommand(t, "", "create", "execution", "--name", "Build-App")
+	expectOutput(t, result.logs, "exists in more than one project (Field Demo, Production)")
+
+	result = runCommand(t, "", "create", "execution", "--name", "Missing-App", "--project", "Production")
+	expectOutput(t, result.logs, "pipeline Missing-App not found in project Production")
+
+	runCommand(t, "", "create", "execution", "--name", "Build-App", "--project", "Production", "--tags", "build-1234,commit-4f2a9c1", "--tags", "nightly")
+	executions := server.List(fakeserver.Executions)
+	if len(executions) != 1 {
+		t.Fatalf("expected 1 execution, got %d", len(executions))
+	}
+	if executions[0]["_pipelineLink"] != "/codestream/api/pipelines/"+productionID {
+		t.Errorf("the execution was created for the wrong pipeline: %v", executions[0]["_pipelineLink"])
+	}
+	if tags := executions[0]["tags"].([]interface{}); len(tags) != 3 || tags[0] != "build-1234" || tags[2] != "nightly" {
+		t.Errorf("the execution was not tagged: %v", tags)
+	}
+}
+
+func TestCreateMatrixExecutions(t *testing.T) {
+	server := newTestServer(t)
+	pipeline := testPipeline("Deploy-App")
+	pipeline["concurrency"] = 2
+	server.Add(fakeserver.Pipelines, pipeline)
+	server.ExecutionPolls = 2
+	server.ExecutionResult = func(execution map[string]interface{}) {
+		input := execution["input"].(map[string]interface{})
+		if input["environment"] == "prod" && input["version"] == "2.0" {
+			execution["status"] = "FAILED"
+			execution["statusMessage"] = "Deploy.Rollout: Timed out."
+		}
+	}
+	matrix := writeTestFile(t, "matrix.yaml", `
+matrix:
+  environment: [dev, prod]
+  version: ["1.0", "2.0"]
+include:
+  - environment: test
+    version: "3.0"
+`)
+
+	result := runCommand(t, "", "create", "execution", "--name", "Deploy-App", "--matrix", matrix, "--interval", "10ms", "--tags", "matrix")
+	expectOutput(t, result.logs, "limiting --concurrency to 2", "1 of 5 executions did not complete successfully")
+	expectOutput(t, result.stdout, "environment=dev, version=1.0", "environment=test, version=3.0", "FAILED", "Deploy.Rollout: Timed out.")
+	if !result.fatal {
+		t.Error("a failed matrix execution did not fail the command")
+	}
+	if executions := server.List(fakeserver.Executions); len(executions) != 5 {
+		t.Errorf("expected 5 executions, got %d", len(executions))
+	}
+
+	result = runCommand(t, "", "create", "execution", "--name", "Deploy-App", "--matrix", matrix, "--input", "region=eu")
+	expectOutput(t, result.logs, "invalid matrix entry environment=dev, version=1.0: unknown input(s) for pipeline Deploy-App: region")
+
+	// Numbers keep their literal text and null is an empty string
+	matrix = writeTestFile(t, "matrix.yaml", `
+inputs:
+  environment: null
+matrix:
+  version: [1.10, 1.20]
+`)
+	runCommand(t, "", "create", "execution", "--name", "Deploy-App", "--matrix", matrix, "--interval", "10ms")
+	versions := make(map[interface{}]bool)
+	for _, execution := range server.List(fakeserver.Executions)[5:] {
+		input := execution["input"].(map[string]interface{})
+		if input["environment"] != "" {
+			t.Errorf("the shared null input was sent as %q", input["environment"])
+		}
+		versions[input["version"]] = true
+	}
+	if len(versions) != 2 || !versions["1.10"] || !versions["1.20"] {
+		t.Errorf("the matrix versions were changed: %v", versions)
+	}
+}
+
+func TestMatrixExecutionTimeout(t *testing.T) {
+	server := newTestServer(t)
+	pipeline := testPipeline("Deploy-App")
+	pipeline["concurrency"] = 1
+	server.Add(fakeserver.Pipelines, pipeline)
+	server.ExecutionPolls = 1000000 // e.g. waiting for an approval
+	matrix := writeTestFile(t, "matrix.yaml", "matrix:\n  environment: [dev, prod]\n")
+
+	result := runCommand(t, "", "create", "execution", "--name", "Deploy-App", "--matrix", matrix, "--interval", "10ms", "--timeout", "50ms")
+	if !result.fatal {
+		t.Error("timed out executions did not fail the command")
+	}
+	expectOutput(t, result.logs, "2 of 2 executions did not complete successfully")
+	expectOutput(t, result.stdout, "environment=dev", "Still RUNNING after --timeout", "environment=prod", "Not started before --timeout")
+	if executions := server.List(fakeserver.Executions); len(executions) != 1 {
+		t.Errorf("expected 1 execution, got %d", len(executions))
+	}
+}
+
+// addExecution - adds a finished execution of the pipeline requested age ago
+func addExecution(server *fakeserver.Server, pipeline string, index int, status string, age time.Duration, duration time.Duration, failedTask string, message string) string {
+	execution := map[string]interface{}{
+		"name":                   pipeline,
+		"project":                testProject,
+		"index":                  index,
+		"status":                 status,
+		"statusMessage":          message,
+		"stageOrder":             []interface{}{"Build"},
+		"_requestTimeInMicros":   time.Now().Add(-age).UnixNano() / 1000,
+		"_totalDurationInMicros": duration.Microseconds(),
+		"_executedBy":            testUsername,
+		"_nested":                false,
+		"_rollback":              false,
+	}
+	if failedTask != "" {
+		execution["stages"] = map[string]interface{}{
+			"Build": map[string]interface{}{
+				"status": "FAILED",
+				"tasks": map[string]interface{}{
+					failedTask: map[string]interface{}{"status": "FAILED"},
+					"Lint":     map[string]interface{}{"status": "COMPLETED"},
+				},
+			},
+		}
+	}
+	return server.Add(fakeserver.Executions, execution)
+}
+
+func TestReportExecutions(t *testing.T) {
+	server := newTestServer(t)
+	day := 24 * time.Hour
+	for i := 1; i <= 8; i++ {
+		addExecution(server, "Build-App", i, "COMPLETED", time.Duration(i)*day, time.Duration(i)*time.Minute, "", "Execution Completed.")
+	}
+	addExecution(server, "Build-App", 9, "FAILED", 2*day, 20*time.Minute, "Compile", "Build.Compile: Script execution failed.")
+	addExecution(server, "Build-App", 10, "FAILED", 3*day, 30*time.Second, "Compile", "Build.Compile: Script execution failed.")
+	addExecution(server, "Deploy-App", 1, "FAILED", 40*day, time.Minute, "Rollout", "Timed out.")
+	addExecution(server, "Deploy-App", 2, "RUNNING", time.Hour, 0, "", "")
+
+	result := runCommand(t, "", "report", "executions", "--project", testProject, "--since", "30d", "--count", "3")
+	expectOutput(t, result.stdout, "Build-App", "80.0%", "Build.Compile (2)", "Build.Compile: Script", "Deploy-App")
+
+	result = runCommand(t, "", "report", "executions", "--project", testProject, "--since", "30d", "--output", "json")
+	var reports []executionReport
+	if err := json.Unmarshal([]byte(result.stdout), &reports); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, result.stdout)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 pipelines, got %d", len(reports))
+	}
+	build := reports[0]
+	if build.Runs != 10 || build.Completed != 8 || build.Failed != 2 {
+		t.Errorf("wrong counts for Build-App: %+v", build)
+	}
+	if build.P50DurationMicros != (4*time.Minute).Microseconds() || build.P95DurationMicros != (20*time.Minute).Microseconds() {
+		t.Errorf("wrong percentiles for Build-App: p50 %d p95 %d", build.P50DurationMicros, build.P95DurationMicros)
+	}
+	if deploy := reports[1]; deploy.Runs != 1 || deploy.Running != 1 || deploy.Failed != 0 {
+		t.Errorf("executions before --since were included for Deploy-App: %+v", deploy)
+	}
+
+	result = runCommand(t, "", "report", "executions", "--project", testProject, "--output", "csv")
+	if lines := strings.Split(strings.TrimSpace(result.stdout), "\n"); len(lines) != 3 || !strings.HasPrefix(lines[0], "Pipeline,Project,Runs") {
+		t.Errorf("unexpected CSV output:\n%s", result.stdout)
+	}
+
+	result = runCommand(t, "", "report", "executions", "--project", testProject, "--output", "markdown")
+	expectOutput(t, result.stdout, "| Pipeline ", "|---")
+
+	result = runCommand(t, "", "report", "executions", "--output", "xml")
+	if result.err == nil {
+		t.Error("an unknown output format was accepted")
+	}
+}
+
+func TestPruneExecutions(t *testing.T) {
+	server := newTestServer(t)
+	day := 24 * time.Hour
+	runs := []struct {
+		status string
+		age    int
+	}{{"COMPLETED", 26}, {"RUNNING", 25}, {"COMPLETED", 24}, {"CANCELED", 23}, {"COMPLETED", 22}, {"FAILED", 21}, {"COMPLETED", 20}, {"COMPLETED", 3}, {"FAILED", 2}, {"COMPLETED", 1}}
+	for i, run := range runs {
+		addExecution(server, "Build-App", i+1, run.status, time.Duration(run.age)*day, time.Minute, "", "")
+	}
+	addExecution(server, "Deploy-App", 1, "FAILED", 40*day, time.Minute, "", "")
+	prune := []string{"prune", "executions", "--project", testProject, "--keep-last", "3", "--older-than", "14d", "--status", "COMPLETED,FAILED"}
+
+	result := runCommand(t, "", append(prune, "--dry-run")...)
+	expectOutput(t, result.stdout, "Build-App#7", "Build-App#6", "Build-App#5", "Build-App#3", "Build-App#1")
+	expectOutput(t, result.logs, "Dry run: 5 of 11 executions would be deleted")
+	for _, kept := range []string{"Build-App#10", "Build-App#8", "Build-App#4", "Build-App#2", "Deploy-App#1"} {
+		if strings.Contains(result.stdout, kept+" ") {
+			t.Errorf("%s would be deleted", kept)
+		}
+	}
+	if len(server.List(fakeserver.Executions)) != 11 {
+		t.Fatal("executions were deleted in a dry run")
+	}
+
+	result = runCommand(t, "y\n", append(prune, "--concurrency", "2")...)
+	expectOutput(t, result.logs, "[5/5] Deleted", "5 Executions deleted")
+	var remaining []string
+	for _, execution := range server.List(fakeserver.Executions) {
+		remaining = append(remaining, fmt.Sprintf("%s#%v", execution["name"], execution["index"]))
+	}
+	if strings.Join(remaining, " ") != "Build-App#2 Build-App#4 Build-App#8 Build-App#9 Build-App#10 Deploy-App#1" {
+		t.Errorf("the wrong executions were deleted, remaining: %v", remaining)
+	}
+
+	result = runCommand(t, "", "prune", "executions", "--project", testProject)
+	expectOutput(t, result.logs, "--keep-last and/or --older-than is required")
+}
+
+func TestExportExecutions(t *testing.T) {
+	server := newTestServer(t)
+	day := 24 * time.Hour
+	addExecution(server, "Build-App", 1, "COMPLETED", 100*day, time.Minute, "", "")
+	failed := addExecution(server, "Build-App", 2, "FAILED", 10*day, time.Minute, "Compile", "Build.Compile: Script execution failed.")
+	server.SetExecutionLogs(failed, map[string]interface{}{"Build.Compile": []interface{}{"make: *** [all] Error 2"}})
+	running := addExecution(server, "Build-App", 3, "RUNNING", day, 0, "", "")
+	outDir := t.TempDir()
+
+	// An export that fails part way keeps the executions written so far in the index
+	runningFile := filepath.Join(outDir, "Build-App-3-"+running+".json")
+	os.Mkdir(runningFile, 0755)
+	result := runCommand(t, "", "export", "executions", "--project", testProject, "--since", "90d", "--out", outDir)
+	expectOutput(t, result.logs, "Unable to export executions")
+	indexBytes, err := os.ReadFile(filepath.Join(outDir, "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOutput(t, string(indexBytes), `"file": "Build-App-2-`+failed+`.json"`)
+	os.Remove(runningFile)
+
+	result = runCommand(t, "", "export", "executions", "--project", testProject, "--since", "90d", "--out", outDir)
+	expectOutput(t, result.logs, "Exported 1 executions to "+outDir+" - 2 executions in the index")
+	exported, err := os.ReadFile(filepath.Join(outDir, "Build-App-2-"+failed+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOutput(t, string(exported), `"_executedBy": "test-user"`, `"statusMessage": "Build.Compile: Script execution failed."`, `"Compile": {`,
+		`"workspaceLogs": {`, `"make: *** [all] Error 2"`)
+	if files, _ := filepath.Glob(filepath.Join(outDir, "Build-App-1-*.json")); len(files) > 0 {
+		t.Error("an execution requested before --since was exported")
+	}
+
+	// Only the running execution and new executions are exported again
+	server.Add(fakeserver.Executions, map[string]interface{}{"id": running, "name": "Build-App", "project": testProject, "index": 3, "status": "COMPLETED", "_requestTimeInMicros": server.Get(fakeserver.Executions, running)["_requestTimeInMicros"]})
+	addExecution(server, "Build-App", 4, "COMPLETED", time.Hour, time.Minute, "", "")
+	result = runCommand(t, "", "export", "executions", "--project", testProject, "--since", "90d", "--out", outDir)
+	expectOutput(t, result.logs, "Exported 2 executions to "+outDir+" - 3 executions in the index")
+
+	var index executionIndex
+	indexBytes, err = os.ReadFile(filepath.Join(outDir, "index.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(indexBytes, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Executions) != 3 || index.Executions[0].Index != 4 || index.Executions[1].Status != "COMPLETED" || index.Executions[2].File != "Build-App-2-"+failed+".json" {
+		t.Errorf("unexpected index: %+v", index.Executions)
+	}
+
+	result = runCommand(t, "", "export", "executions", "--project", "Other", "--out", outDir)
+	expectOutput(t, result.logs, "is an export of project Field Demo, not Other")
+}
+
+func TestExportExecutionsNameFilter(t *testing.T) {
+	server := newTestServer(t)
+	day := 24 * time.Hour
+	addExecution(server, "Other", 1, "COMPLETED", 5*day, time.Minute, "", "")
+	addExecution(server, "Build-App", 1, "COMPLETED", day, time.Minute, "", "")
+	outDir := t.TempDir()
+
+	result := runCommand(t, "", "export", "executions", "--project", testProject, "--name", "Build-App", "--since", "30d", "--out", outDir)
+	expectOutput(t, result.logs, "Exported 1 executions to "+outDir+" - 1 executions in the index")
+
+	// The bounds of the Build-App export would skip the older execution of Other
+	result = runCommand(t, "", "export", "executions", "--project", testProject, "--since", "30d", "--out", outDir)
+	expectOutput(t, result.logs, "is an export of pipeline Build-App, not all pipelines")
+	result = runCommand(t, "", "export", "executions", "--project", testProject, "--name", "Other", "--since", "30d", "--out", outDir)
+	expectOutput(t, result.logs, "is an export of pipeline Build-App, not pipeline Other")
+
+	allDir := t.TempDir()
+	result = runCommand(t, "", "export", "executions", "--project", testProject, "--since", "30d", "--out", allDir)
+	expectOutput(t, result.logs, "Exported 2 executions to "+allDir+" - 2 executions in the index")
+	if files, _ := filepath.Glob(filepath.Join(allDir, "Other-1-*.json")); len(files) != 1 {
+		t.Error("the execution of Other was not exported")
+	}
+}
+
+func TestGetExecutionTimeAndMetadataFilters(t *testing.T) {
+	server := newTestServer(t)
+	day := 24 * time.Hour
+	addExecution(server, "Build-App", 1, "COMPLETED", 10*day, 3*time.Minute, "", "")
+	addExecution(server, "Build-App", 2, "COMPLETED", 4*day, 90*time.Minute, "", "")
+	addExecution(server, "Build-App", 3, "COMPLETED", 3*time.Hour, 45*time.Second, "", "")
+	server.Add(fakeserver.Executions, map[string]interface{}{"name": "Deploy-App", "project": testProject, "index": 1, "status": "ROLLBACK_COMPLETED", "_rollback": true, "_executedBy": "release-bot", "tags": []interface{}{"build-1234", "nightly"}, "_pipelineLink": "/codestream/api/pipelines/deploy-app-id"})
+
+	result := runCommand(t, "", "get", "execution", "--since", "5d", "--until", "1d")
+	expectOutput(t, result.stdout, `"index": 2`)
+
+	result = runCommand(t, "", "get", "execution", "--name", "Build-App", "--sort", "duration")
+	expectOutput(t, result.stdout, "Build-App#2", "1h30m0s", "3m0s", "45s", "4d ago", "3h ago")
+	if strings.Index(result.stdout, "Build-App#2") > strings.Index(result.stdout, "Build-App#1") || strings.Index(result.stdout, "Build-App#1") > strings.Index(result.stdout, "Build-App#3") {
+		t.Errorf("executions were not sorted by duration:\n%s", result.stdout)
+	}
+	result = runCommand(t, "", "get", "execution", "--name", "Build-App", "--ascending")
+	if strings.Index(result.stdout, "Build-App#1") > strings.Index(result.stdout, "Build-App#3") {
+		t.Errorf("executions were not sorted oldest first:\n%s", result.stdout)
+	}
+
+	for _, filter := range [][]string{{"--executed-by", "release-bot"}, {"--tag", "build-1234", "--tag", "nightly"}, {"--rollback"}, {"--pipeline-id", "deploy-app-id"}, {"--filter", "status eq 'ROLLBACK_COMPLETED'"}} {
+		result = runCommand(t, "", append([]string{"get", "execution"}, filter...)...)
+		expectOutput(t, result.stdout, `"name": "Deploy-App"`)
+	}
+	result = runCommand(t, "", "get", "execution", "--tag", "build-1234", "--tag", "release")
+	expectOutput(t, result.logs, "No results found")
+	result = runCommand(t, "", "get", "execution", "--rollback=false", "--filter", "index gt 1")
+	expectOutput(t, result.stdout, "Build-App#2", "Build-App#3")
+
+	result = runCommand(t, "", "get", "execution", "--sort", "name")
+	expectOutput(t, result.logs, `unknown sort \"name\", expected time or duration`)
+}
+
+func TestWatchExecutions(t *testing.T) {
+	server := newTestServer(t)
+	running := addExecution(server, "Deploy-App", 1, "RUNNING", time.Hour, 0, "", "")
+	addExecution(server, "Build-App", 1, "COMPLETED", time.Minute, time.Minute, "", "")
+
+	webhooks := make(chan executionWebhookPayload, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload executionWebhookPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		webhooks <- payload
+	}))
+	defer webhook.Close()
+
+	var listRequests int
+	server.BeforeRequest = func(r *http.Request) {
+		if r.URL.Path != "/pipeline/api/executions" {
+			return
+		}
+		// Between the first and second refresh, the running execution fails and a new one completes
+		if listRequests++; listRequests == 3 {
+			failed := server.Get(fakeserver.Executions, running)
+			failed["status"] = "FAILED"
+			failed["statusMessage"] = "Deploy.Rollout: Timed out."
+			failed["stageOrder"] = []interface{}{"Deploy"}
+			failed["stages"] = map[string]interface{}{"Deploy": map[string]interface{}{"status": "FAILED", "tasks": map[string]interface{}{"Rollout": map[string]interface{}{"status": "FAILED"}}}}
+			server.Add(fakeserver.Executions, failed)
+			addExecution(server, "Build-App", 2, "COMPLETED", 0, time.Minute, "", "")
+		}
+	}
+
+	result := runCommand(t, "", "watch", "executions", "--project", testProject, "--count", "1", "--iterations", "2", "--interval", "10ms", "--webhook", webhook.URL)
+	refreshes := strings.Split(result.stdout, "Executions at")
+	if len(refreshes) != 3 {
+		t.Fatalf("expected 2 refreshes, got:\n%s", result.stdout)
+	}
+	expectOutput(t, refreshes[1], "Deploy-App#1", "RUNNING", "Build-App#1")
+	expectOutput(t, refreshes[2], "RUNNING -> FAILED", "Deploy.Rollout", "* Build-App#2")
+	expectOutput(t, result.logs, "Execution Deploy-App#1 failed: Deploy.Rollout: Timed out.")
+
+	select {
+	case payload := <-webhooks:
+		if payload.Execution.ID != running || payload.Execution.Stage != "Deploy.Rollout" || !strings.Contains(payload.Text, "Deploy-App#1") {
+			t.Errorf("unexpected webhook payload: %+v", payload)
+		}
+	default:
+		t.Error("the webhook was not called")
+	}
+	if len(webhooks) != 0 {
+		t.Error("the webhook was called for executions that did not fail")
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var checkFormat bool
+var canonical bool
+
+// canonicalKeys - the keys that come first, in this order, for each kind of document. The other keys
+// follow alphabetically.
+var canonicalKeys = map[string][]string{
+	"PIPELINE": {"project", "kind", "name", "icon", "enabled", "description", "concurrency", "input", "_inputMeta", "workspace", "stageOrder", "stages", "notifications", "options", "rollbacks", "tags"},
+	"ENDPOINT": {"project", "kind", "name", "description", "type", "isRestricted", "properties"},
+	"VARIABLE": {"project", "kind", "name", "description", "type", "value"},
+	"stage":    {"taskOrder", "tasks", "tags"},
+	"task":     {"type", "ignoreFailure", "preCondition", "endpoints", "input", "tags"},
+}
+
+// canonicalYaml - rewrite pipeline, endpoint and variable YAML documents in a canonical form: keys in a
+// stable order, stages and tasks in stageOrder and taskOrder, and multi-line strings as block scalars
+func canonicalYaml(yamlBytes []byte) ([]byte, error) {
+	var documents [][]byte
+	decoder := yaml.NewDecoder(bytes.NewReader(yamlBytes))
+	for {
+		var document yaml.MapSlice
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if document == nil {
+			continue
+		}
+		canonicalBytes, err := yaml.Marshal(canonicalDocument(document))
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, canonicalBytes)
+	}
+	if len(documents) == 0 {
+		return nil, errors.New("no YAML documents found")
+	}
+	if len(documents) == 1 && !bytes.HasPrefix(bytes.TrimSpace(yamlBytes), []byte("---")) {
+		return documents[0], nil
+	}
+	var canonicalBytes []byte
+	for _, document := range documents {
+		canonicalBytes = append(canonicalBytes, "---\n"...)
+		canonicalBytes = append(canonicalBytes, document...)
+	}
+	return canonicalBytes, nil
+}
+
+// canonicalDocument - a pipeline, endpoint or variable document in canonical form
+func canonicalDocument(document yaml.MapSlice) yaml.MapSlice {
+	kind := strings.ToUpper(fmt.Sprint(mapValue(document, "kind")))
+	return orderKeys(document, canonicalKeys[kind], nil, func(key string, value interface{}) interface{} {
+		if kind == "PIPELINE" && key == "stages" {
+			if stages, ok := value.(yaml.MapSlice); ok {
+				return canonicalStages(stages, stringList(mapValue(document, "stageOrder")))
+			}
+		}
+		return canonicalValue(value)
+	})
+}
+
+// canonicalStages - the stages in stageOrder, each with its tasks in taskOrder
+func canonicalStages(stages yaml.MapSlice, stageOrder []string) yaml.MapSlice {
+	return orderKeys(stages, nil, stageOrder, func(_ string, value interface{}) interface{} {
+		stage, ok := value.(yaml.MapSlice)
+		if !ok {
+			return canonicalValue(value)
+		}
+		var taskOrder []string
+		for _, entry := range stringList(mapValue(stage, "taskOrder")) {
+			for _, task := range strings.Split(entry, ",") {
+				taskOrder = append(taskOrder, strings.TrimSpace(task))
+			}
+		}
+		return orderKeys(stage, canonicalKeys["stage"], nil, func(key string, value interface{}) interface{} {
+			tasks, ok := value.(yaml.MapSlice)
+			if key != "tasks" || !ok {
+				return canonicalValue(value)
+			}
+			return orderKeys(tasks, nil, taskOrder, func(_ string, task interface{}) interface{} {
+				if t, ok := task.(yaml.MapSlice); ok {
+					return orderKeys(t, canonicalKeys["task"], nil, func(_ string, value interface{}) interface{} {
+						return canonicalValue(value)
+					})
+				}
+				return canonicalValue(task)
+			})
+		})
+	})
+}
+
+// canonicalValue - maps with their keys sorted, and multi-line strings normalized
+func canonicalValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case yaml.MapSlice:
+		return orderKeys(typed, nil, nil, func(_ string, value interface{}) interface{} {
+			return canonicalValue(value)
+		})
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = canonicalValue(item)
+		}
+	case string:
+		return normalizeMultiline(typed)
+	}
+	return value
+}
+
+// normalizeMultiline - multi-line strings with Unix line endings and no trailing whitespace on each line,
+// so they can be written as block scalars
+func normalizeMultiline(s string) string {
+	if !strings.Contains(s, "\n") {
+		return s
+	}
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// orderKeys - the map with the keys listed in first, then those listed in order, then the rest sorted
+// alphabetically. Each value is replaced by the result of value.
+func orderKeys(m yaml.MapSlice, first []string, order []string, value func(key string, v interface{}) interface{}) yaml.MapSlice {
+	rank := make(map[string]int)
+	for i, key := range append(append([]string{}, first...), order...) {
+		if _, ok := rank[key]; !ok {
+			rank[key] = i
+		}
+	}
+	ordered := make(yaml.MapSlice, len(m))
+	copy(ordered, m)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := fmt.Sprint(ordered[i].Key), fmt.Sprint(ordered[j].Key)
+		rankA, rankedA := rank[a]
+		rankB, rankedB := rank[b]
+		switch {
+		case rankedA && rankedB:
+			return rankA < rankB
+		case rankedA || rankedB:
+			return rankedA
+		}
+		return a < b
+	})
+	for i, item := range ordered {
+		ordered[i].Value = value(fmt.Sprint(item.Key), item.Value)
+	}
+	return ordered
+}
+
+// mapValue - the value of a key in the map, or nil
+func mapValue(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if fmt.Sprint(item.Key) == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// stringList - the items of a YAML list as strings
+func stringList(value interface{}) []string {
+	var list []string
+	items, _ := value.([]interface{})
+	for _, item := range items {
+		list = append(list, fmt.Sprint(item))
+	}
+	return list
+}
+
+// fmtCmd represents the fmt command
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [path...]",
+	Short: "Rewrite pipeline, endpoint and variable YAML in canonical form",
+	Long: `Rewrite pipeline, endpoint and variable YAML files in a canonical form, so exports produce clean
+diffs: keys in a stable order, stages and tasks in stageOrder and taskOrder, and multi-line scripts
+as block scalars (with trailing whitespace removed). Comments are not kept.
+
+# Format a file, or every YAML file in a folder
+cs-cli fmt pipelines/Build-App.yaml
+cs-cli fmt pipelines/ endpoints/
+# List the files that are not in canonical form, e.g. in a CI check
+cs-cli fmt --check pipelines/`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var changed, failed int
+		for _, arg := range args {
+			for _, yamlFilePath := range getYamlFilePaths(arg) {
+				yamlBytes, err := ioutil.ReadFile(yamlFilePath)
+				if err != nil {
+					log.Warnln("Unable to read", yamlFilePath+":", err)
+					failed++
+					continue
+				}
+				canonicalBytes, err := canonicalYaml(yamlBytes)
+				if err != nil {
+					log.Warnln("Unable to format", yamlFilePath+":", err)
+					failed++
+					continue
+				}
+				if bytes.Equal(yamlBytes, canonicalBytes) {
+					continue
+				}
+				changed++
+				if checkFormat {
+					fmt.Println(yamlFilePath)
+					continue
+				}
+				info, _ := os.Stat(yamlFilePath)
+				if err := ioutil.WriteFile(yamlFilePath, canonicalBytes, info.Mode()); err != nil {
+					log.Warnln("Unable to write", yamlFilePath+":", err)
+					failed++
+					continue
+				}
+				log.Infoln("Formatted", yamlFilePath)
+			}
+		}
+		if failed > 0 {
+			log.Fatalln(failed, "files could not be formatted")
+		}
+		if checkFormat && changed > 0 {
+			log.Fatalln(changed, "files are not in canonical form")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fmtCmd)
+	fmtCmd.Flags().BoolVarP(&checkFormat, "check", "", false, "List the files that are not in canonical form instead of rewriting them")
+}
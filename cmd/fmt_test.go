@@ -0,0 +1,145 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware/code-stream-cli/internal/fakeserver"
+)
+
+const unformattedPipeline = `stages:
+  Release:
+    tasks:
+      Publish:
+        input:
+          script: "echo publish  \r\necho done"
+        type: SSH
+    taskOrder:
+      - Publish
+  Build:
+    tasks:
+      Lint:
+        type: CI
+        ignoreFailure: true
+      Compile:
+        type: CI
+    taskOrder:
+      - Compile,Lint
+name: Release-App
+kind: PIPELINE
+stageOrder:
+  - Build
+  - Release
+project: Field Demo
+enabled: true
+`
+
+func TestCanonicalYaml(t *testing.T) {
+	canonicalBytes, err := canonicalYaml([]byte(unformattedPipeline))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `project: Field Demo
+kind: PIPELINE
+name: Release-App
+enabled: true
+stageOrder:
+- Build
+- Release
+stages:
+  Build:
+    taskOrder:
+    - Compile,Lint
+    tasks:
+      Compile:
+        type: CI
+      Lint:
+        type: CI
+        ignoreFailure: true
+  Release:
+    taskOrder:
+    - Publish
+    tasks:
+      Publish:
+        type: SSH
+        input:
+          script: |-
+            echo publish
+            echo done
+`
+	if string(canonicalBytes) != expected {
+		t.Errorf("unexpected canonical YAML:\n%s", canonicalBytes)
+	}
+	again, err := canonicalYaml(canonicalBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(again) != string(canonicalBytes) {
+		t.Errorf("canonical YAML changed when formatted again:\n%s", again)
+	}
+
+	variables := "---\nvalue: a\nname: one\nkind: VARIABLE\ntype: REGULAR\nproject: Field Demo\n---\nname: two\nkind: VARIABLE\n"
+	canonicalBytes, err = canonicalYaml([]byte(variables))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOutput(t, string(canonicalBytes), "---\nproject: Field Demo\nkind: VARIABLE\nname: one\ntype: REGULAR\nvalue: a\n---\nkind: VARIABLE\nname: two\n")
+
+	if _, err := canonicalYaml([]byte("")); err == nil {
+		t.Error("an empty file was formatted")
+	}
+}
+
+func TestFmt(t *testing.T) {
+	newTestServer(t)
+	path := writeTestFile(t, "Release-App.yaml", unformattedPipeline)
+
+	result := runCommand(t, "", "fmt", "--check", filepath.Dir(path))
+	expectOutput(t, result.stdout, path)
+	expectOutput(t, result.logs, "1 files are not in canonical form")
+	if b, _ := os.ReadFile(path); string(b) != unformattedPipeline {
+		t.Error("--check rewrote the file")
+	}
+
+	result = runCommand(t, "", "fmt", path)
+	expectOutput(t, result.logs, "Formatted "+path)
+	formatted, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(formatted), "project: Field Demo\nkind: PIPELINE\n") {
+		t.Errorf("the file was not formatted:\n%s", formatted)
+	}
+
+	result = runCommand(t, "", "fmt", "--check", path)
+	if result.fatal || result.stdout != "" {
+		t.Errorf("a formatted file failed --check: %s%s", result.stdout, result.logs)
+	}
+
+	broken := writeTestFile(t, "broken.yaml", "name: [unclosed")
+	result = runCommand(t, "", "fmt", broken)
+	expectOutput(t, result.logs, "Unable to format "+broken, "1 files could not be formatted")
+}
+
+func TestExportCanonicalPipeline(t *testing.T) {
+	server := newTestServer(t)
+	server.Add(fakeserver.Pipelines, testFlowPipeline())
+	exportDir := t.TempDir()
+
+	runCommand(t, "", "get", "pipeline", "--name", "Release-App", "--exportPath", exportDir, "--canonical")
+	exported, err := os.ReadFile(filepath.Join(exportDir, "Release-App.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonicalBytes, err := canonicalYaml(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(canonicalBytes) != string(exported) {
+		t.Errorf("the export is not in canonical form:\n%s", exported)
+	}
+	expectOutput(t, string(exported), "project: Field Demo\nkind: PIPELINE\nname: Release-App\n")
+}
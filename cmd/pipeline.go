@@ -9,20 +9,29 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
-	"sort"
+	"strconv"
 	"strings"
 
-	"github.com/mitchellh/mapstructure"
 	"github.com/olekukonko/tablewriter"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 var state string
 var printForm bool
 var dependencies bool
+var pipelineTags []string
+var listPath string
+var toName string
+var toProject string
+var toTarget string
+var mappingPath string
+var valuesPath string
+var describeFormat string
+var force bool
+var extractScripts bool
 
 // getPipelineCmd represents the pipeline command
 var getPipelineCmd = &cobra.Command{
@@ -36,7 +45,9 @@ cs-cli get execution --id 9cc5aedc-db48-4c02-a5e4-086de3160dc0
 # View executions of a specific pipeline
 get execution --name vra-authenticateUser
 # View executions by status
-cs-cli get execution --status Failed`,
+cs-cli get execution --status Failed
+# Export a pipeline with its dependencies, writing ${secret.name} in place of SECRET and RESTRICTED variable values
+cs-cli get pipeline --name Build-App --exportPath exports/ --exportDependencies --secret-placeholders`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := ensureTargetConnection(); err != nil {
 			log.Fatalln(err)
@@ -62,81 +73,13 @@ cs-cli get execution --status Failed`,
 				PrettyPrint(c.Input)
 			}
 		} else {
-			var endpoints []string
-			var variables []string
-			var pipelines []string
-			var customintegrations []string
-
 			// Print result table
 			table := tablewriter.NewWriter(os.Stdout)
 			table.SetHeader([]string{"Id", "Name", "Project", "Description"})
 			for _, c := range response {
-				if c.Workspace.Endpoint != "" {
-					endpoints = append(endpoints, c.Workspace.Endpoint)
-				}
-
 				table.Append([]string{c.ID, c.Name, c.Project, c.Description})
-				for _, s := range c.Stages {
-					stage := CodeStreamPipelineStage{}
-					mapstructure.Decode(s, &stage)
-					// Loop through the Stage Tasks
-					for n, t := range stage.Tasks {
-						taskString := fmt.Sprintf("%v", t)
-						rxp := regexp.MustCompile(`\$\{var\.(.*?)\}`) // Match ${var.name}
-						variableMatches := rxp.FindAllStringSubmatch(taskString, -1)
-						for _, v := range variableMatches {
-							variables = append(variables, v[1])
-						}
-						task := CodeStreamPipelineTask{}
-						mapstructure.Decode(t, &task)
-						if len(task.Endpoints) > 0 {
-							for _, e := range task.Endpoints {
-								endpoints = append(endpoints, e)
-							}
-						}
-						//PrettyPrint(task)
-						if task.Type == "Pipeline" {
-							pipelines = append(pipelines, task.Input.Pipeline)
-						}
-						if task.Type == "Custom" {
-							customintegrations = append(customintegrations, task.Input.Name)
-						}
-						log.Debugln("-- [Task]", n, "(", task.Type, ")")
-					}
-				}
 				if dependencies {
-					variables = removeDuplicateStrings(variables)
-					sort.Strings(variables)
-					if len(variables) > 0 {
-						log.Infoln(c.Name, "depends on Variables:", strings.Join(variables, ", "))
-						for _, v := range variables {
-							getVariable("", v, c.Project, exportPath)
-						}
-					}
-					pipelines = removeDuplicateStrings(pipelines)
-					sort.Strings(pipelines)
-					if len(pipelines) > 0 {
-						log.Infoln(c.Name, "depends on Pipelines:", strings.Join(pipelines, ", "))
-						for _, p := range pipelines {
-							getPipelines("", p, c.Project, filepath.Join(exportPath, "pipelines"))
-						}
-					}
-					endpoints = removeDuplicateStrings(endpoints)
-					sort.Strings(endpoints)
-					if len(endpoints) > 0 {
-						log.Infoln(c.Name, "depends on Endpoints:", strings.Join(endpoints, ", "))
-						for _, e := range endpoints {
-							getEndpoint("", e, c.Project, "", filepath.Join(exportPath, "endpoints"))
-						}
-					}
-					customintegrations = removeDuplicateStrings(customintegrations)
-					sort.Strings(customintegrations)
-					if len(customintegrations) > 0 {
-						log.Infoln(c.Name, "depends on Custom Integrations:", strings.Join(customintegrations, ", "))
-						for _, ci := range customintegrations {
-							getCustomIntegration("", ci)
-						}
-					}
+					exportPipelineDependencies(c)
 				}
 			}
 			table.Render()
@@ -144,6 +87,61 @@ cs-cli get execution --status Failed`,
 	},
 }
 
+// exportPipelineDependencies - log the endpoints, pipelines, variables and custom integrations a pipeline
+// depends on, exporting them to --exportPath
+func exportPipelineDependencies(c *CodeStreamPipeline) {
+	d := getPipelineDependencies(c)
+	if len(d.Variables) > 0 {
+		log.Infoln(c.Name, "depends on Variables:", strings.Join(d.Variables, ", "))
+		for _, v := range d.Variables {
+			getVariable("", v, c.Project, exportPath)
+		}
+	}
+	if len(d.Pipelines) > 0 {
+		log.Infoln(c.Name, "depends on Pipelines:", strings.Join(d.Pipelines, ", "))
+		for _, p := range d.Pipelines {
+			getPipelines("", p, c.Project, filepath.Join(exportPath, "pipelines"))
+		}
+	}
+	if len(d.Endpoints) > 0 {
+		log.Infoln(c.Name, "depends on Endpoints:", strings.Join(d.Endpoints, ", "))
+		for _, e := range d.Endpoints {
+			getEndpoint("", e, c.Project, "", filepath.Join(exportPath, "endpoints"))
+		}
+	}
+	if len(d.CustomIntegrations) > 0 {
+		log.Infoln(c.Name, "depends on Custom Integrations:", strings.Join(d.CustomIntegrations, ", "))
+		for _, ci := range d.CustomIntegrations {
+			getCustomIntegration("", ci)
+		}
+	}
+}
+
+// checkDependentPipelines - list the pipelines in the project that use the variables or endpoints
+// (kind "variable" or "endpoint") about to be deleted or renamed, and stop unless --force is set
+func checkDependentPipelines(project string, kind string, names []string, action string) {
+	dependents, err := findDependentPipelines(project, kind, names)
+	if err != nil {
+		log.Fatalln("Unable to check which Pipelines use the "+kind+":", err)
+	}
+	if len(dependents) == 0 {
+		return
+	}
+	var rows [][]string
+	pipelines := make(map[string]bool)
+	for _, d := range dependents {
+		pipelines[d.Pipeline.ID] = true
+		rows = append(rows, []string{d.Pipeline.Name, d.Pipeline.Project, d.Kind + " " + d.Name})
+	}
+	PrintRows("table", []string{"Pipeline", "Project", "Uses"}, rows)
+	message := fmt.Sprintf("%d Pipelines use the %s(s) to be %s", len(pipelines), kind, action)
+	if force {
+		log.Warnln(message + ", continuing because of --force")
+		return
+	}
+	log.Fatalln(message + ", use --force to continue anyway")
+}
+
 // updatePipelineCmd represents the pipeline update command
 var updatePipelineCmd = &cobra.Command{
 	Use:   "pipeline",
@@ -151,8 +149,16 @@ var updatePipelineCmd = &cobra.Command{
 	Long: `Update a Pipeline
 # Enable/Disable/Release:
 cs-cli update pipeline --id d0185f04-2e87-4f3c-b6d7-ee58abba3e92 --state enabled/disabled/released
+# Enable/Disable/Release in bulk, by name, project and/or tag, or from a file listing one pipeline name or ID per line
+cs-cli update pipeline --project "Field Demo" --state disabled
+cs-cli update pipeline --project "Field Demo" --tag production --state released
+cs-cli update pipeline --list change-freeze.txt --state disabled
 # Update from YAML
 cs-cli update pipeline --importPath "/Users/sammcgeown/Desktop/pipelines/SSH Exports.yaml"
+# Update from a template, replacing placeholders such as {{ .Values.service }} with values from a file
+cs-cli update pipeline --importPath service-pipeline.yaml --values service-a.yaml
+# Update, renaming the endpoints, variables and nested pipelines used
+cs-cli update pipeline --importPath exports/pipelines --mapping prod-mapping.yaml
 	`,
 	Args: func(cmd *cobra.Command, args []string) error {
 		if state != "" {
@@ -170,29 +176,74 @@ cs-cli update pipeline --importPath "/Users/sammcgeown/Desktop/pipelines/SSH Exp
 			log.Fatalln(err)
 		}
 
-		if state != "" {
-			response, err := patchPipeline(id, `{"state":"`+state+`"}`)
+		if state != "" && id != "" {
+			response, err := patchPipeline(id, `{"state":"`+strings.ToUpper(state)+`"}`)
 			if err != nil {
-				log.Errorln("Unable to update Code Stream Pipeline: ", err)
+				log.Fatalln("Unable to update Code Stream Pipeline: ", err)
 			}
 			log.Infoln("Setting pipeline " + response.Name + " to " + state)
+		} else if state != "" {
+			if name == "" && project == "" && len(pipelineTags) == 0 && listPath == "" {
+				log.Fatalln("--state requires --id, --name, --project, --tag or --list")
+			}
+			updatePipelinesState(strings.ToUpper(state))
 		}
 
-		yamlFilePaths := getYamlFilePaths(importPath)
-		if len(yamlFilePaths) == 0 {
-			log.Warnln("No YAML files were found in", importPath)
-		}
-		for _, yamlFilePath := range yamlFilePaths {
-			yamlFileName := filepath.Base(yamlFilePath)
-			err := importYaml(yamlFilePath, "apply", "", "endpoint")
-			if err != nil {
-				log.Warnln("Failed to import", yamlFilePath, "as Pipeline", err)
-			}
-			fmt.Println("Imported", yamlFileName, "successfully - Pipeline updated.")
+		if importPath == "" {
+			return
 		}
+		importPipelines("apply", "", "updated")
 	},
 }
 
+// updatePipelinesState - sets the state of the selected pipelines after previewing the changes
+func updatePipelinesState(newState string) {
+	pipelines, err := selectPipelines(name, project, pipelineTags, listPath)
+	if err != nil {
+		log.Fatalln("Unable to get Code Stream Pipelines: ", err)
+	}
+	if len(pipelines) == 0 {
+		log.Warnln("No pipelines matched")
+		return
+	}
+	var changes []*CodeStreamPipeline
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "Project", "State", "New State"})
+	for _, p := range pipelines {
+		currentState := pipelineState(p)
+		if currentState == newState {
+			table.Append([]string{p.Name, p.Project, currentState, "(unchanged)"})
+			continue
+		}
+		changes = append(changes, p)
+		table.Append([]string{p.Name, p.Project, currentState, newState})
+	}
+	table.Render()
+	unchanged := len(pipelines) - len(changes)
+	if unchanged > 0 {
+		log.Infoln(unchanged, "of", len(pipelines), "pipelines are already", newState)
+	}
+	if len(changes) == 0 {
+		return
+	}
+	if !askForConfirmation("This will set " + fmt.Sprint(len(changes)) + " Pipelines to " + newState + ", are you sure?") {
+		log.Fatalln("user declined")
+	}
+	var updated int
+	for _, p := range changes {
+		if _, err := patchPipeline(p.ID, `{"state":"`+newState+`"}`); err != nil {
+			log.Warnln("Unable to set pipeline", p.Name, "to", newState+":", err)
+			continue
+		}
+		updated++
+		log.Infoln("Setting pipeline " + p.Name + " to " + newState)
+	}
+	log.Infoln(updated, "Pipelines set to", newState+",", unchanged, "already", newState)
+	if updated < len(changes) {
+		log.Fatalln(len(changes)-updated, "Pipelines could not be updated")
+	}
+}
+
 // createPipelineCmd represents the pipeline create command
 var createPipelineCmd = &cobra.Command{
 	Use:   "pipeline",
@@ -201,6 +252,10 @@ var createPipelineCmd = &cobra.Command{
 	
 # Create from YAML
 cs-cli create pipeline --importPath "/Users/sammcgeown/Desktop/pipelines/SSH Exports.yaml"
+# Create from a template, replacing placeholders such as {{ .Values.service }} with values from a file
+cs-cli create pipeline --importPath service-pipeline.yaml --values service-a.yaml
+# Promote to another project, renaming the endpoints, variables and nested pipelines used
+cs-cli create pipeline --importPath exports/pipelines --project Production --mapping prod-mapping.yaml
 	`,
 	Args: func(cmd *cobra.Command, args []string) error {
 		return nil
@@ -209,22 +264,237 @@ cs-cli create pipeline --importPath "/Users/sammcgeown/Desktop/pipelines/SSH Exp
 		if err := ensureTargetConnection(); err != nil {
 			log.Fatalln(err)
 		}
-		yamlFilePaths := getYamlFilePaths(importPath)
-		if len(yamlFilePaths) == 0 {
-			log.Warnln("No YAML files were found in", importPath)
+		importPipelines("create", project, "created")
+	},
+}
+
+// importPipelines - import the pipeline YAML files in --importPath, rendering templates with --values and
+// renaming the references in each pipeline with --mapping, then report every substitution made
+func importPipelines(action string, project string, result string) {
+	values := pipelineTemplateValues()
+	mapping := pipelineMapping()
+	yamlFilePaths := getYamlFilePaths(importPath)
+	if len(yamlFilePaths) == 0 {
+		log.Warnln("No YAML files were found in", importPath)
+	}
+	var rows [][]string
+	for _, yamlFilePath := range yamlFilePaths {
+		yamlFileName := filepath.Base(yamlFilePath)
+		substitutions, err := importYaml(yamlFilePath, action, project, "pipeline", values, mapping)
+		if err != nil {
+			log.Warnln("Failed to import", yamlFilePath, "as Pipeline", err)
+			continue
 		}
-		for _, yamlFilePath := range yamlFilePaths {
-			yamlFileName := filepath.Base(yamlFilePath)
-			err := importYaml(yamlFilePath, "create", project, "pipeline")
-			if err != nil {
-				log.Warnln("Failed to import", yamlFilePath, "as Pipeline", err)
-			} else {
-				fmt.Println("Imported", yamlFileName, "successfully - Pipeline created.")
-			}
+		fmt.Println("Imported", yamlFileName, "successfully - Pipeline "+result+".")
+		for _, s := range substitutions {
+			rows = append(rows, []string{yamlFileName, s.Path, s.From, s.To})
+		}
+	}
+	if mapping != nil {
+		log.Infoln(len(rows), "references rewritten using", mappingPath)
+		if len(rows) > 0 {
+			PrintRows("table", []string{"File", "Path", "From", "To"}, rows)
+		}
+	}
+}
+
+// pipelineMapping - the mapping to rename pipeline references with, nil unless --mapping is set
+func pipelineMapping() *CodeStreamPipelineMapping {
+	if mappingPath == "" {
+		return nil
+	}
+	mapping, err := readPipelineMapping(mappingPath)
+	if err != nil {
+		log.Fatalln("Unable to read the mapping file:", err)
+	}
+	return mapping
+}
+
+// pipelineTemplateValues - the values to render pipeline templates with, nil unless --values is set
+func pipelineTemplateValues() map[string]interface{} {
+	if valuesPath == "" {
+		return nil
+	}
+	values, err := readTemplateValues(valuesPath)
+	if err != nil {
+		log.Fatalln("Unable to read the values file:", err)
+	}
+	return values
+}
+
+// describePipelineCmd represents the describe pipeline command
+var describePipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Describe the flow of a Pipeline",
+	Long: `Describe the stages and tasks of a Pipeline, in order, as an ASCII flow, or as a Mermaid or
+Graphviz DOT graph for documentation. Tasks run in parallel are grouped together.
+
+# Show the flow of a pipeline in the terminal
+cs-cli describe pipeline --name "Build-App" --project "Field Demo"
+# Generate a Mermaid flowchart
+cs-cli describe pipeline --name "Build-App" --output mermaid > build-app.mmd
+# Generate a DOT graph and render it with Graphviz
+cs-cli describe pipeline --name "Build-App" --output dot | dot -Tpng -o build-app.png`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		switch strings.ToLower(describeFormat) {
+		case "ascii", "mermaid", "dot":
+			return nil
+		}
+		return errors.New("--output is not valid, must be ascii, mermaid or dot")
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureTargetConnection(); err != nil {
+			log.Fatalln(err)
+		}
+		pipeline, err := getPipeline(id, name, project)
+		if err != nil {
+			log.Fatalln("Unable to get Code Stream Pipeline: ", err)
+		}
+		stages := pipelineStages(pipeline)
+		switch strings.ToLower(describeFormat) {
+		case "mermaid":
+			fmt.Print(describePipelineMermaid(stages))
+		case "dot":
+			fmt.Print(describePipelineDOT(pipeline, stages))
+		default:
+			fmt.Print(describePipelineASCII(pipeline, stages))
 		}
 	},
 }
 
+// taskLabel - the name of a task followed by its type
+func taskLabel(task pipelineTaskNode) string {
+	if task.Task.Type == "" {
+		return task.Name
+	}
+	return task.Name + " [" + task.Task.Type + "]"
+}
+
+// treeBranch - the indent below a tree node, continuing the branch unless the node is the last
+func treeBranch(last bool) string {
+	if last {
+		return "    "
+	}
+	return "|   "
+}
+
+// describePipelineASCII - the stages and tasks of a pipeline as a tree
+func describePipelineASCII(pipeline *CodeStreamPipeline, stages []pipelineStageNode) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (%s)\n", pipeline.Name, pipeline.Project)
+	for i, stage := range stages {
+		stagePrefix := treeBranch(i == len(stages)-1)
+		b.WriteString("|\n")
+		if stage.Missing {
+			fmt.Fprintf(&b, "+-- %s (not defined)\n", stage.Name)
+			continue
+		}
+		fmt.Fprintf(&b, "+-- %s\n", stage.Name)
+		for j, group := range stage.Groups {
+			last := j == len(stage.Groups)-1
+			if len(group) == 1 {
+				fmt.Fprintf(&b, "%s+-- %s\n", stagePrefix, taskLabel(group[0]))
+				for _, detail := range group[0].details() {
+					fmt.Fprintf(&b, "%s%s    %s\n", stagePrefix, treeBranch(last), detail)
+				}
+				continue
+			}
+			fmt.Fprintf(&b, "%s+-+ %d in parallel\n", stagePrefix, len(group))
+			groupPrefix := stagePrefix + treeBranch(last)[:2]
+			for k, task := range group {
+				fmt.Fprintf(&b, "%s+-- %s\n", groupPrefix, taskLabel(task))
+				for _, detail := range task.details() {
+					fmt.Fprintf(&b, "%s%s    %s\n", groupPrefix, treeBranch(k == len(group)-1)[:2], detail)
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// pipelineGraph - an ID for each task, by stage and group, and the edges between tasks that run one after the other
+func pipelineGraph(stages []pipelineStageNode) ([][][]string, [][2]string) {
+	var ids [][][]string
+	var edges [][2]string
+	var previous []string
+	for i, stage := range stages {
+		var stageIDs [][]string
+		n := 0
+		for _, group := range stage.Groups {
+			var groupIDs []string
+			for range group {
+				groupIDs = append(groupIDs, fmt.Sprintf("s%dt%d", i, n))
+				n++
+			}
+			for _, from := range previous {
+				for _, to := range groupIDs {
+					edges = append(edges, [2]string{from, to})
+				}
+			}
+			previous = groupIDs
+			stageIDs = append(stageIDs, groupIDs)
+		}
+		ids = append(ids, stageIDs)
+	}
+	return ids, edges
+}
+
+// describePipelineMermaid - the stages and tasks of a pipeline as a Mermaid flowchart
+func describePipelineMermaid(stages []pipelineStageNode) string {
+	escape := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+	ids, edges := pipelineGraph(stages)
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	for i, stage := range stages {
+		label := stage.Name
+		if stage.Missing {
+			label += " (not defined)"
+		}
+		fmt.Fprintf(&b, "  subgraph s%d[\"%s\"]\n", i, escape.Replace(label))
+		for j, group := range stage.Groups {
+			for k, task := range group {
+				lines := append([]string{taskLabel(task)}, task.details()...)
+				for l := range lines {
+					lines[l] = escape.Replace(lines[l])
+				}
+				fmt.Fprintf(&b, "    %s[\"%s\"]\n", ids[i][j][k], strings.Join(lines, "<br/>"))
+			}
+		}
+		b.WriteString("  end\n")
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(&b, "  %s --> %s\n", edge[0], edge[1])
+	}
+	return b.String()
+}
+
+// describePipelineDOT - the stages and tasks of a pipeline as a Graphviz DOT graph
+func describePipelineDOT(pipeline *CodeStreamPipeline, stages []pipelineStageNode) string {
+	ids, edges := pipelineGraph(stages)
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(pipeline.Name))
+	b.WriteString("  rankdir=LR;\n  node [shape=box];\n")
+	for i, stage := range stages {
+		label := stage.Name
+		if stage.Missing {
+			label += " (not defined)"
+		}
+		fmt.Fprintf(&b, "  subgraph \"cluster_s%d\" {\n    label=%s;\n", i, strconv.Quote(label))
+		for j, group := range stage.Groups {
+			for k, task := range group {
+				lines := append([]string{taskLabel(task)}, task.details()...)
+				fmt.Fprintf(&b, "    %q [label=%s];\n", ids[i][j][k], strconv.Quote(strings.Join(lines, "\n")))
+			}
+		}
+		b.WriteString("  }\n")
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(&b, "  %q -> %q;\n", edge[0], edge[1])
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
 // deletePipelineCmd represents the delete pipeline command
 var deletePipelineCmd = &cobra.Command{
 	Use:   "pipeline",
@@ -266,6 +536,72 @@ cs-cli delete pipeline --project "My Project"
 	},
 }
 
+// copyPipelineCmd represents the copy pipeline command
+var copyPipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Copy a Pipeline",
+	Long: `Copy a Pipeline under a new name, in the same or another project or target.
+The pipeline is exported, renamed and imported as a new pipeline.
+
+# Copy a pipeline in the same project
+cs-cli copy pipeline --name "Golden Pipeline" --project "Field Demo" --to-name "Service A"
+# Copy a pipeline to another project on another target, renaming the endpoints and variables it uses
+cs-cli copy pipeline --name "Golden Pipeline" --project "Field Demo" --to-name "Service A" --to-project "Production" --to-target prod --mapping prod-mapping.yaml
+
+The mapping file gives the new name for each endpoint, variable or nested pipeline:
+endpoints:
+  Docker-Host: Docker-Host-Prod
+variables:
+  deploy-token: prod-deploy-token
+pipelines:
+  Run-Tests: Run-Tests-Prod`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureTargetConnection(); err != nil {
+			log.Fatalln(err)
+		}
+		mapping := pipelineMapping()
+		source, err := getPipeline(id, name, project)
+		if err != nil {
+			log.Fatalln("Unable to get Code Stream Pipeline: ", err)
+		}
+		destinationProject := toProject
+		if destinationProject == "" {
+			destinationProject = source.Project
+		}
+		if toTarget == "" && toName == source.Name && destinationProject == source.Project {
+			log.Fatalln("The copy needs a different --to-name, --to-project or --to-target")
+		}
+		yamlBytes, err := exportYamlBytes(source.Name, source.Project, "pipelines")
+		if err != nil {
+			log.Fatalln("Unable to export Code Stream Pipeline: ", err)
+		}
+		yamlBytes, substitutions, err := rewritePipelineYaml(yamlBytes, toName, destinationProject, mapping)
+		if err != nil {
+			log.Fatalln("Unable to rewrite Code Stream Pipeline: ", err)
+		}
+		for _, s := range substitutions {
+			log.Infoln("Rewrote", s)
+		}
+		if toTarget != "" {
+			if viper.Get("server") != nil { // CS_SERVER environment variable is set, so there is no config file
+				log.Fatalln("--to-target needs the target in a config file, it cannot be used with the CS_SERVER environment variables")
+			}
+			destination, err := readTargetConfig(toTarget)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			currentTargetName, targetConfig = toTarget, destination
+			if err := ensureTargetConnection(); err != nil {
+				log.Fatalln(err)
+			}
+		}
+		if err := importYamlBytes(yamlBytes, "create"); err != nil {
+			log.Fatalln("Unable to create Code Stream Pipeline", toName+":", err)
+		}
+		log.Infoln("Copied pipeline", source.Name, "to", toName, "in", destinationProject)
+	},
+}
+
 func init() {
 	// Get
 	getCmd.AddCommand(getPipelineCmd)
@@ -273,20 +609,47 @@ func init() {
 	getPipelineCmd.Flags().StringVarP(&id, "id", "i", "", "ID of the pipeline to list")
 	getPipelineCmd.Flags().StringVarP(&project, "project", "p", "", "List pipeline in project")
 	getPipelineCmd.Flags().StringVarP(&exportPath, "exportPath", "", "", "Path to export objects - relative or absolute location")
+	getPipelineCmd.Flags().BoolVarP(&canonical, "canonical", "", false, "Export the YAML in canonical form (see cs-cli fmt)")
+	getPipelineCmd.Flags().BoolVarP(&extractScripts, "extractScripts", "", false, "Export task scripts to .sh/.ps1 files next to the YAML, referenced with $include")
 	getPipelineCmd.Flags().BoolVarP(&printForm, "form", "f", false, "Return pipeline inputs form(s)")
 	getPipelineCmd.Flags().BoolVarP(&printJson, "json", "", false, "Return JSON formatted Pipeline(s)")
 	getPipelineCmd.Flags().BoolVarP(&dependencies, "exportDependencies", "", false, "Export Pipeline dependencies (Endpoint, Pipelines, Variables, Custom Integrations)")
+	getPipelineCmd.Flags().BoolVarP(&secretPlaceholders, "secret-placeholders", "", false, "Export the SECRET and RESTRICTED values of dependent Variables as ${secret.name} placeholders")
 
 	// Create
 	createCmd.AddCommand(createPipelineCmd)
 	createPipelineCmd.Flags().StringVarP(&importPath, "importPath", "", "", "YAML configuration file to import")
 	createPipelineCmd.Flags().StringVarP(&project, "project", "p", "", "Manually specify the Project in which to create the Pipeline (overrides YAML)")
+	createPipelineCmd.Flags().StringVarP(&valuesPath, "values", "", "", "YAML file of values to render the pipeline template(s) with")
+	createPipelineCmd.Flags().StringVarP(&mappingPath, "mapping", "", "", "YAML file mapping the endpoint, variable and pipeline names to use in the imported pipeline(s)")
 	createPipelineCmd.MarkFlagRequired("importPath")
 	// Update
 	updateCmd.AddCommand(updatePipelineCmd)
 	updatePipelineCmd.Flags().StringVarP(&id, "id", "i", "", "ID of the pipeline to list")
 	updatePipelineCmd.Flags().StringVarP(&importPath, "importPath", "", "", "Configuration file to import")
+	updatePipelineCmd.Flags().StringVarP(&valuesPath, "values", "", "", "YAML file of values to render the pipeline template(s) with")
+	updatePipelineCmd.Flags().StringVarP(&mappingPath, "mapping", "", "", "YAML file mapping the endpoint, variable and pipeline names to use in the imported pipeline(s)")
 	updatePipelineCmd.Flags().StringVarP(&state, "state", "s", "", "Set the state of the pipeline (ENABLED|DISABLED|RELEASED")
+	updatePipelineCmd.Flags().StringVarP(&name, "name", "n", "", "Name of the pipeline(s) to set the state of")
+	updatePipelineCmd.Flags().StringVarP(&project, "project", "p", "", "Set the state of pipelines in the Project")
+	updatePipelineCmd.Flags().StringSliceVarP(&pipelineTags, "tag", "", []string{}, "Set the state of pipelines with the tag (can be repeated, all tags must match)")
+	updatePipelineCmd.Flags().StringVarP(&listPath, "list", "", "", "File listing the name or ID of each pipeline to set the state of, one per line")
+	// Copy
+	copyCmd.AddCommand(copyPipelineCmd)
+	copyPipelineCmd.Flags().StringVarP(&id, "id", "i", "", "ID of the pipeline to copy")
+	copyPipelineCmd.Flags().StringVarP(&name, "name", "n", "", "Name of the pipeline to copy")
+	copyPipelineCmd.Flags().StringVarP(&project, "project", "p", "", "Project of the pipeline to copy")
+	copyPipelineCmd.Flags().StringVarP(&toName, "to-name", "", "", "Name of the copy")
+	copyPipelineCmd.Flags().StringVarP(&toProject, "to-project", "", "", "Project to create the copy in (default is the project of the pipeline)")
+	copyPipelineCmd.Flags().StringVarP(&toTarget, "to-target", "", "", "Target to create the copy on (default is the current target)")
+	copyPipelineCmd.Flags().StringVarP(&mappingPath, "mapping", "", "", "YAML file mapping the endpoint, variable and pipeline names to use in the copy")
+	copyPipelineCmd.MarkFlagRequired("to-name")
+	// Describe
+	describeCmd.AddCommand(describePipelineCmd)
+	describePipelineCmd.Flags().StringVarP(&id, "id", "i", "", "ID of the pipeline to describe")
+	describePipelineCmd.Flags().StringVarP(&name, "name", "n", "", "Name of the pipeline to describe")
+	describePipelineCmd.Flags().StringVarP(&project, "project", "p", "", "Project of the pipeline to describe")
+	describePipelineCmd.Flags().StringVarP(&describeFormat, "output", "o", "ascii", "Output format (ascii|mermaid|dot)")
 	// Delete
 	deleteCmd.AddCommand(deletePipelineCmd)
 	deletePipelineCmd.Flags().StringVarP(&id, "id", "i", "", "ID of the Pipeline to delete")
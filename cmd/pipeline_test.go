@@ -0,0 +1,413 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware/code-stream-cli/internal/fakeserver"
+)
+
+func TestGetPipeline(t *testing.T) {
+	server := newTestServer(t)
+	id := server.Add(fakeserver.Pipelines, testPipeline("Build-App"))
+	server.Add(fakeserver.Pipelines, testPipeline("Deploy-App"))
+
+	result := runCommand(t, "", "get", "pipeline")
+	expectOutput(t, result.stdout, "Build-App", "Deploy-App", testProject)
+
+	result = runCommand(t, "", "get", "pipeline", "--id", id, "--json")
+	expectOutput(t, result.stdout, `"name": "Build-App"`, `"stageOrder"`)
+
+	result = runCommand(t, "", "get", "pipeline", "--name", "Deploy-App", "--form")
+	expectOutput(t, result.stdout, `"environment": "dev"`)
+
+	result = runCommand(t, "", "get", "pipeline", "--name", "Missing")
+	expectOutput(t, result.logs, "No results found")
+}
+
+func TestExportPipeline(t *testing.T) {
+	server := newTestServer(t)
+	server.Add(fakeserver.Pipelines, testPipeline("Build-App"))
+	exportDir := t.TempDir()
+
+	runCommand(t, "", "get", "pipeline", "--name", "Build-App", "--exportPath", exportDir)
+	exported, err := os.ReadFile(filepath.Join(exportDir, "Build-App.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOutput(t, string(exported), "name: Build-App", "kind: PIPELINE", "stageOrder:")
+}
+
+func TestExportPipelineDependencySecrets(t *testing.T) {
+	server := newTestServer(t)
+	server.Add(fakeserver.Pipelines, testPipeline("Build-App"))
+	server.Add(fakeserver.Variables, map[string]interface{}{"name": "deploy-token", "project": testProject, "type": "RESTRICTED", "value": "t0ken"})
+	exportDir := t.TempDir()
+
+	runCommand(t, "", "get", "pipeline", "--name", "Build-App", "--exportPath", exportDir, "--exportDependencies", "--secret-placeholders")
+	exported, err := os.ReadFile(filepath.Join(exportDir, "variables.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOutput(t, string(exported), "name: deploy-token", "value: ${secret.deploy-token}")
+	if strings.Contains(string(exported), "t0ken") {
+		t.Error("the RESTRICTED value of a dependency was exported")
+	}
+}
+
+func TestCreateAndUpdatePipeline(t *testing.T) {
+	server := newTestServer(t)
+
+	result := runCommand(t, "", "create", "pipeline", "--importPath", "testdata/pipeline.yaml", "--project", testProject)
+	expectOutput(t, result.stdout, "Imported pipeline.yaml successfully - Pipeline created.")
+	pipeline := server.Find(fakeserver.Pipelines, "Imported-Pipeline", testProject)
+	if pipeline == nil {
+		t.Fatal("pipeline was not created in the --project")
+	}
+
+	result = runCommand(t, "", "create", "pipeline", "--importPath", "testdata/pipeline.yaml", "--project", testProject)
+	expectOutput(t, result.logs, "Failed to import", "already exists")
+
+	result = runCommand(t, "", "update", "pipeline", "--id", pipeline["id"].(string), "--state", "DISABLED")
+	expectOutput(t, result.logs, "Setting pipeline Imported-Pipeline to DISABLED")
+	if server.Get(fakeserver.Pipelines, pipeline["id"].(string))["enabled"] != false {
+		t.Error("pipeline was not disabled")
+	}
+}
+
+func TestDeletePipeline(t *testing.T) {
+	server := newTestServer(t)
+	id := server.Add(fakeserver.Pipelines, testPipeline("Build-App"))
+	server.Add(fakeserver.Pipelines, testPipeline("Deploy-App"))
+	server.Add(fakeserver.Pipelines, testPipeline("Test-App"))
+
+	result := runCommand(t, "", "delete", "pipeline", "--id", id)
+	expectOutput(t, result.logs, "Pipeline with id "+id+" deleted")
+
+	result = runCommand(t, "n\n", "delete", "pipeline", "--project", testProject)
+	expectOutput(t, result.logs, "user declined")
+	if len(server.List(fakeserver.Pipelines)) != 2 {
+		t.Fatal("pipelines were deleted although the prompt was declined")
+	}
+
+	result = runCommand(t, "y\n", "delete", "pipeline", "--project", testProject)
+	expectOutput(t, result.logs, "2 Pipelines deleted")
+	if len(server.List(fakeserver.Pipelines)) != 0 {
+		t.Error("pipelines in the project were not deleted")
+	}
+}
+
+func TestUpdatePipelineStateInBulk(t *testing.T) {
+	server := newTestServer(t)
+	server.AddProject("Production")
+	buildID := server.Add(fakeserver.Pipelines, testPipeline("Build-App"))
+	deploy := testPipeline("Deploy-App")
+	deploy["tags"] = []interface{}{"production"}
+	deployID := server.Add(fakeserver.Pipelines, deploy)
+	disabled := testPipeline("Test-App")
+	disabled["enabled"], disabled["state"] = false, "DISABLED"
+	server.Add(fakeserver.Pipelines, disabled)
+	other := testPipeline("Build-App")
+	other["project"] = "Production"
+	otherID := server.Add(fakeserver.Pipelines, other)
+
+	result := runCommand(t, "n\n", "update", "pipeline", "--project", testProject, "--state", "disabled")
+	expectOutput(t, result.stdout, "Build-App", "Deploy-App", "(unchanged)")
+	expectOutput(t, result.logs, "1 of 3 pipelines are already DISABLED", "This will set 2 Pipelines to DISABLED", "user declined")
+	if server.Get(fakeserver.Pipelines, buildID)["enabled"] != true {
+		t.Fatal("a pipeline was disabled although the prompt was declined")
+	}
+
+	result = runCommand(t, "y\n", "update", "pipeline", "--project", testProject, "--state", "disabled")
+	expectOutput(t, result.logs, "2 Pipelines set to DISABLED, 1 already DISABLED")
+	if server.Get(fakeserver.Pipelines, buildID)["state"] != "DISABLED" || server.Get(fakeserver.Pipelines, otherID)["state"] != "ENABLED" {
+		t.Error("the pipelines in --project were not the only ones disabled")
+	}
+
+	result = runCommand(t, "y\n", "update", "pipeline", "--tag", "production", "--state", "released")
+	expectOutput(t, result.logs, "Setting pipeline Deploy-App to RELEASED", "1 Pipelines set to RELEASED")
+	if server.Get(fakeserver.Pipelines, deployID)["state"] != "RELEASED" {
+		t.Error("the tagged pipeline was not released")
+	}
+
+	list := writeTestFile(t, "freeze.txt", "# change freeze\n"+otherID+"\nTest-App\n")
+	result = runCommand(t, "y\n", "update", "pipeline", "--list", list, "--state", "enabled")
+	expectOutput(t, result.logs, "1 of 2 pipelines are already ENABLED", "Setting pipeline Test-App to ENABLED")
+
+	list = writeTestFile(t, "ambiguous.txt", "Build-App\n")
+	result = runCommand(t, "", "update", "pipeline", "--list", list, "--state", "enabled")
+	expectOutput(t, result.logs, "exists in more than one project")
+
+	result = runCommand(t, "", "update", "pipeline", "--state", "enabled")
+	expectOutput(t, result.logs, "--state requires --id, --name, --project, --tag or --list")
+}
+
+func TestCopyPipeline(t *testing.T) {
+	server := newTestServer(t)
+	server.AddProject("Production")
+	golden := testPipeline("Golden")
+	golden["tags"] = []interface{}{"golden"}
+	server.Add(fakeserver.Pipelines, golden)
+	mapping := writeTestFile(t, "mapping.yaml", "endpoints:\n  Docker-Host: Docker-Host-Prod\n  SSH-Host: SSH-Host-Prod\nvariables:\n  deploy-token: prod-deploy-token\n")
+
+	result := runCommand(t, "", "copy", "pipeline", "--name", "Golden", "--project", testProject, "--to-name", "Service-A")
+	expectOutput(t, result.logs, "Copied pipeline Golden to Service-A in "+testProject)
+	copied := server.Find(fakeserver.Pipelines, "Service-A", testProject)
+	if copied == nil {
+		t.Fatal("the copy was not created")
+	}
+	expectOutput(t, fmt.Sprint(copied), "Docker-Host", "${var.deploy-token}", "golden")
+
+	result = runCommand(t, "", "copy", "pipeline", "--name", "Golden", "--project", testProject, "--to-name", "Service-A")
+	expectOutput(t, result.logs, "already exists")
+
+	result = runCommand(t, "", "copy", "pipeline", "--name", "Golden", "--to-name", "Service-A", "--to-project", "Production", "--mapping", mapping)
+	expectOutput(t, result.logs,
+		"Rewrote project: "+testProject+" -> Production",
+		"Rewrote stages.Build.tasks.Deploy.endpoints.agent: SSH-Host -> SSH-Host-Prod",
+		"Rewrote stages.Build.tasks.Deploy.input.script: ${var.deploy-token} -> ${var.prod-deploy-token}",
+		"Rewrote workspace.endpoint: Docker-Host -> Docker-Host-Prod")
+	copied = server.Find(fakeserver.Pipelines, "Service-A", "Production")
+	if copied == nil {
+		t.Fatal("the copy was not created in --to-project")
+	}
+	if copied["workspace"].(map[string]interface{})["endpoint"] != "Docker-Host-Prod" {
+		t.Error("the workspace endpoint was not mapped")
+	}
+	expectOutput(t, fmt.Sprint(copied), "SSH-Host-Prod", "deploy --token ${var.prod-deploy-token} --env ${input.environment}")
+
+	result = runCommand(t, "", "copy", "pipeline", "--name", "Golden", "--to-name", "Golden")
+	expectOutput(t, result.logs, "The copy needs a different --to-name, --to-project or --to-target")
+
+	result = runCommand(t, "", "copy", "pipeline", "--name", "Golden", "--to-name", "Golden", "--to-target", "prod")
+	expectOutput(t, result.logs, "--to-target needs the target in a config file, it cannot be used with the CS_SERVER environment variables")
+
+	bad := writeTestFile(t, "bad.yaml", "endpoint:\n  a: b\n")
+	result = runCommand(t, "", "copy", "pipeline", "--name", "Golden", "--to-name", "Service-B", "--mapping", bad)
+	expectOutput(t, result.logs, "Unable to read the mapping file")
+}
+
+func TestCopyPipelineToTarget(t *testing.T) {
+	server := newTestServer(t)
+	server.Add(fakeserver.Pipelines, testPipeline("Golden"))
+	address := useConfigFile(t)
+	production := fakeserver.New(testUsername, testPassword)
+	production.AddProject("Production")
+	productionServer := httptest.NewServer(production)
+	t.Cleanup(productionServer.Close)
+
+	runCommand(t, "", "config", "set-target", "--name", "lab", "--server", address, "--username", testUsername, "--password", testPassword)
+	runCommand(t, "", "config", "set-target", "--name", "prod", "--server", productionServer.Listener.Addr().String(), "--username", testUsername, "--password", testPassword)
+	runCommand(t, "", "config", "use-target", "--name", "lab")
+
+	result := runCommand(t, "", "copy", "pipeline", "--name", "Golden", "--to-name", "Golden", "--to-project", "Production", "--to-target", "prod")
+	expectOutput(t, result.logs, "Copied pipeline Golden to Golden in Production")
+	if production.Find(fakeserver.Pipelines, "Golden", "Production") == nil {
+		t.Fatal("the copy was not created on --to-target")
+	}
+	if len(server.List(fakeserver.Pipelines)) != 1 {
+		t.Error("the copy was created on the source target")
+	}
+
+	result = runCommand(t, "", "copy", "pipeline", "--name", "Golden", "--to-name", "Golden", "--to-target", "missing")
+	expectOutput(t, result.logs, "Target configuration not found")
+}
+
+func TestImportPipelineWithMapping(t *testing.T) {
+	server := newTestServer(t)
+	server.AddProject("Production")
+	pipeline := writeTestFile(t, "release.yaml", `project: Field Demo
+kind: PIPELINE
+name: Release
+enabled: true
+workspace:
+  endpoint: Docker-Dev
+stageOrder:
+- Release
+stages:
+  Release:
+    taskOrder:
+    - Test,Deploy
+    tasks:
+      Test:
+        type: Pipeline
+        input:
+          pipeline: Run-Tests
+      Deploy:
+        type: SSH
+        endpoints:
+          agent: SSH-Dev
+        input:
+          script: deploy --token ${var.dev-token} --url ${var.url}
+tags:
+- release
+`)
+	mapping := writeTestFile(t, "mapping.yaml", "endpoints:\n  Docker-Dev: Docker-Prod\n  SSH-Dev: SSH-Prod\nvariables:\n  dev-token: prod-token\npipelines:\n  Run-Tests: Run-Tests-Prod\n")
+
+	result := runCommand(t, "", "render", "--importPath", pipeline, "--mapping", mapping)
+	expectOutput(t, result.stdout, "endpoint: Docker-Prod", "pipeline: Run-Tests-Prod", "${var.prod-token} --url ${var.url}")
+
+	result = runCommand(t, "", "create", "pipeline", "--importPath", pipeline, "--project", "Production", "--mapping", mapping)
+	expectOutput(t, result.logs, "5 references rewritten using "+mapping)
+	expectOutput(t, result.stdout,
+		"Imported release.yaml successfully - Pipeline created.",
+		"project", testProject, "Production",
+		"stages.Release.tasks.Test.input.pipeline", "Run-Tests-Prod",
+		"stages.Release.tasks.Deploy.endpoints.agent", "SSH-Prod",
+		"${var.prod-token}",
+		"workspace.endpoint", "Docker-Prod")
+	imported := server.Find(fakeserver.Pipelines, "Release", "Production")
+	if imported == nil {
+		t.Fatal("the pipeline was not imported to --project")
+	}
+	expectOutput(t, fmt.Sprint(imported), "Docker-Prod", "SSH-Prod", "Run-Tests-Prod", "${var.prod-token}", "tags:[release]")
+
+	runCommand(t, "", "create", "pipeline", "--importPath", pipeline)
+	result = runCommand(t, "", "update", "pipeline", "--importPath", pipeline, "--mapping", mapping)
+	expectOutput(t, result.stdout, "Imported release.yaml successfully - Pipeline updated.")
+	expectOutput(t, result.logs, "4 references rewritten")
+	expectOutput(t, fmt.Sprint(server.Find(fakeserver.Pipelines, "Release", testProject)), "Docker-Prod")
+}
+
+// testFlowPipeline - a pipeline with parallel tasks, a nested pipeline and a precondition
+func testFlowPipeline() map[string]interface{} {
+	pipeline := testPipeline("Release-App")
+	pipeline["stageOrder"] = []interface{}{"Build", "Release"}
+	pipeline["stages"].(map[string]interface{})["Build"] = map[string]interface{}{
+		"taskOrder": []interface{}{"Compile", "Unit Tests,Lint", "Deploy"},
+		"tasks": map[string]interface{}{
+			"Compile":    map[string]interface{}{"type": "CI"},
+			"Unit Tests": map[string]interface{}{"type": "CI"},
+			"Lint":       map[string]interface{}{"type": "CI", "ignoreFailure": true},
+			"Deploy": map[string]interface{}{
+				"type":         "SSH",
+				"endpoints":    map[string]interface{}{"agent": "SSH-Host"},
+				"preCondition": `"${input.environment}" == "prod"`,
+			},
+		},
+	}
+	pipeline["stages"].(map[string]interface{})["Release"] = map[string]interface{}{
+		"taskOrder": []interface{}{"Smoke Tests"},
+		"tasks": map[string]interface{}{
+			"Smoke Tests": map[string]interface{}{"type": "Pipeline", "input": map[string]interface{}{"pipeline": "Run-Tests"}},
+		},
+	}
+	return pipeline
+}
+
+func TestDescribePipeline(t *testing.T) {
+	server := newTestServer(t)
+	server.Add(fakeserver.Pipelines, testFlowPipeline())
+
+	result := runCommand(t, "", "describe", "pipeline", "--name", "Release-App")
+	expectOutput(t, result.stdout, `Release-App (Field Demo)
+|
++-- Build
+|   +-- Compile [CI]
+|   +-+ 2 in parallel
+|   | +-- Unit Tests [CI]
+|   | +-- Lint [CI]
+|   |       ignoreFailure: true
+|   +-- Deploy [SSH]
+|           endpoints: agent=SSH-Host
+|           preCondition: "${input.environment}" == "prod"
+|
++-- Release
+    +-- Smoke Tests [Pipeline]
+            pipeline: Run-Tests
+`)
+
+	result = runCommand(t, "", "describe", "pipeline", "--name", "Release-App", "-o", "mermaid")
+	expectOutput(t, result.stdout,
+		"flowchart TD\n",
+		`subgraph s0["Build"]`,
+		`s0t2["Lint [CI]<br/>ignoreFailure: true"]`,
+		`preCondition: #quot;${input.environment}#quot; == #quot;prod#quot;"]`,
+		"s0t0 --> s0t1\n  s0t0 --> s0t2\n  s0t1 --> s0t3\n  s0t2 --> s0t3\n  s0t3 --> s1t0\n")
+
+	result = runCommand(t, "", "describe", "pipeline", "--name", "Release-App", "-o", "dot")
+	expectOutput(t, result.stdout,
+		`digraph "Release-App" {`,
+		`subgraph "cluster_s1" {`,
+		`"s1t0" [label="Smoke Tests [Pipeline]\npipeline: Run-Tests"];`,
+		`"s0t3" -> "s1t0";`)
+
+	result = runCommand(t, "", "describe", "pipeline", "--name", "Release-App", "-o", "png")
+	if result.err == nil {
+		t.Error("an unknown --output was accepted")
+	}
+}
+
+func TestExportPipelineScripts(t *testing.T) {
+	server := newTestServer(t)
+	pipeline := testPipeline("Build-App")
+	tasks := pipeline["stages"].(map[string]interface{})["Build"].(map[string]interface{})["tasks"].(map[string]interface{})
+	tasks["Deploy"].(map[string]interface{})["input"] = map[string]interface{}{"script": "set -e\ndeploy --env ${input.environment}"}
+	tasks["Clean Up"] = map[string]interface{}{"type": "POWERSHELL", "input": map[string]interface{}{"script": "Remove-Item build -Recurse"}}
+	tasks["Compile"] = map[string]interface{}{"type": "CI", "input": map[string]interface{}{"steps": []interface{}{"make", "make test"}}}
+	tasks["Package"] = map[string]interface{}{"type": "CI", "input": map[string]interface{}{"steps": []interface{}{"cat <<EOF > VERSION\n1.0\nEOF"}}}
+	server.Add(fakeserver.Pipelines, pipeline)
+	exportDir := t.TempDir()
+
+	runCommand(t, "", "get", "pipeline", "--name", "Build-App", "--exportPath", exportDir, "--extractScripts")
+	exported, err := os.ReadFile(filepath.Join(exportDir, "Build-App.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOutput(t, string(exported), "$include: Build-App.Build.Deploy.sh", "$include: Build-App.Build.CleanUp.ps1", "$include: Build-App.Build.Compile.sh", "cat <<EOF > VERSION")
+	for file, content := range map[string]string{
+		"Build-App.Build.Deploy.sh":   "set -e\ndeploy --env ${input.environment}\n",
+		"Build-App.Build.CleanUp.ps1": "Remove-Item build -Recurse\n",
+		"Build-App.Build.Compile.sh":  "make\nmake test\n",
+	} {
+		if b, err := os.ReadFile(filepath.Join(exportDir, file)); err != nil || string(b) != content {
+			t.Errorf("unexpected %s: %q %v", file, b, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(exportDir, "Build-App.Build.Package.sh")); err == nil {
+		t.Error("a multi-line CI step was extracted")
+	}
+
+	os.WriteFile(filepath.Join(exportDir, "Build-App.Build.Deploy.sh"), []byte("set -e\ndeploy --env prod\n"), 0644)
+	server.AddProject("Production")
+	result := runCommand(t, "", "create", "pipeline", "--importPath", filepath.Join(exportDir, "Build-App.yaml"), "--project", "Production")
+	expectOutput(t, result.stdout, "Pipeline created")
+	imported := server.Find(fakeserver.Pipelines, "Build-App", "Production")
+	if imported == nil {
+		t.Fatal("the pipeline was not imported")
+	}
+	importedTasks := imported["stages"].(map[string]interface{})["Build"].(map[string]interface{})["tasks"].(map[string]interface{})
+	if script := importedTasks["Deploy"].(map[string]interface{})["input"].(map[string]interface{})["script"]; script != "set -e\ndeploy --env prod" {
+		t.Errorf("the script was not inlined: %q", script)
+	}
+	if steps := fmt.Sprint(importedTasks["Compile"].(map[string]interface{})["input"].(map[string]interface{})["steps"]); steps != "[make make test]" {
+		t.Errorf("the CI steps were not inlined: %s", steps)
+	}
+
+	result = runCommand(t, "", "render", "--importPath", filepath.Join(exportDir, "Build-App.yaml"))
+	expectOutput(t, result.stdout, "Remove-Item build -Recurse")
+	os.Remove(filepath.Join(exportDir, "Build-App.Build.CleanUp.ps1"))
+	result = runCommand(t, "", "create", "pipeline", "--importPath", filepath.Join(exportDir, "Build-App.yaml"), "--project", testProject)
+	expectOutput(t, result.logs, "Build-App.Build.CleanUp.ps1")
+
+	// An include can only read files next to the YAML
+	secret := writeTestFile(t, "secret.txt", "token")
+	os.Symlink(secret, filepath.Join(exportDir, "link.sh"))
+	for _, include := range []string{secret, "../" + filepath.Base(filepath.Dir(secret)) + "/secret.txt", "link.sh"} {
+		yamlPath := filepath.Join(exportDir, "Include.yaml")
+		os.WriteFile(yamlPath, []byte("kind: PIPELINE\nname: Include\nstages: {}\nscript:\n  $include: "+include+"\n"), 0644)
+		result = runCommand(t, "", "create", "pipeline", "--importPath", yamlPath, "--project", testProject)
+		expectOutput(t, result.logs, "$include "+include+" is outside the directory of the pipeline YAML")
+		if server.Find(fakeserver.Pipelines, "Include", testProject) != nil {
+			t.Fatalf("%s was included", include)
+		}
+	}
+}
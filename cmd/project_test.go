@@ -0,0 +1,24 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetProject(t *testing.T) {
+	server := newTestServer(t)
+	server.AddProject("Production")
+
+	result := runCommand(t, "", "get", "project")
+	expectOutput(t, result.stdout, testProject, "Production")
+
+	result = runCommand(t, "", "get", "project", "--name", "Production")
+	expectOutput(t, result.stdout, "Production")
+	if strings.Contains(result.stdout, testProject) {
+		t.Error("--name returned other projects")
+	}
+}
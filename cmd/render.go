@@ -0,0 +1,75 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// renderCmd represents the render command
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Preview pipeline templates rendered with values and mappings",
+	Long: `Render pipeline templates locally, without importing them. Placeholders such as
+{{ .Values.service }} are replaced with values from the values file, and every placeholder
+has to resolve. Go template syntax is supported, so a literal {{ has to be written {{ "{{" }}.
+
+# Preview a pipeline template
+cs-cli render --importPath service-pipeline.yaml --values service-a.yaml
+# Preview it with the endpoints, variables and nested pipelines renamed by a mapping file
+cs-cli render --importPath service-pipeline.yaml --values service-a.yaml --mapping prod-mapping.yaml
+# Apply it
+cs-cli create pipeline --importPath service-pipeline.yaml --values service-a.yaml`,
+	Run: func(cmd *cobra.Command, args []string) {
+		values := make(map[string]interface{})
+		if valuesPath != "" {
+			values = pipelineTemplateValues()
+		}
+		mapping := pipelineMapping()
+		yamlFilePaths := getYamlFilePaths(importPath)
+		var failed int
+		for i, yamlFilePath := range yamlFilePaths {
+			var substitutions []pipelineSubstitution
+			templateBytes, err := ioutil.ReadFile(yamlFilePath)
+			if err == nil {
+				templateBytes, err = renderTemplate(filepath.Base(yamlFilePath), templateBytes, values)
+			}
+			if err == nil {
+				templateBytes, err = inlinePipelineScripts(templateBytes, filepath.Dir(yamlFilePath))
+			}
+			if err == nil && mapping != nil {
+				templateBytes, substitutions, err = rewritePipelineYaml(templateBytes, "", "", mapping)
+			}
+			for _, s := range substitutions {
+				log.Infoln(filepath.Base(yamlFilePath)+":", "Rewrote", s)
+			}
+			if err != nil {
+				log.Warnln("Failed to render", yamlFilePath+":", err)
+				failed++
+				continue
+			}
+			if i > 0 {
+				fmt.Println("---")
+			}
+			fmt.Print(string(templateBytes))
+		}
+		if failed > 0 {
+			log.Fatalln(failed, "of", len(yamlFilePaths), "templates could not be rendered")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+	renderCmd.Flags().StringVarP(&importPath, "importPath", "", "", "Template file, or folder of templates, to render")
+	renderCmd.Flags().StringVarP(&valuesPath, "values", "", "", "YAML file of values to render the template(s) with")
+	renderCmd.Flags().StringVarP(&mappingPath, "mapping", "", "", "YAML file mapping the endpoint, variable and pipeline names to use in the rendered pipeline(s)")
+	renderCmd.MarkFlagRequired("importPath")
+}
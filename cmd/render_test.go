@@ -0,0 +1,69 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/vmware/code-stream-cli/internal/fakeserver"
+)
+
+const testTemplateValues = `project: Field Demo
+service: payments
+environment: staging
+docker:
+  endpoint: Docker-Host
+  image: golang:1.17
+`
+
+func TestRenderPipelineTemplate(t *testing.T) {
+	values := writeTestFile(t, "values.yaml", testTemplateValues)
+
+	result := runCommand(t, "", "render", "--importPath", "testdata/pipeline-template.yaml", "--values", values)
+	expectOutput(t, result.stdout,
+		"name: payments-Build",
+		`environment: "staging"`,
+		"endpoint: Docker-Host",
+		"image: golang:1.17",
+		"docker ps --format '{{.Names}}'",
+		"make build SERVICE=payments TOKEN=${var.build-token}")
+
+	missing := writeTestFile(t, "values.yaml", strings.Replace(testTemplateValues, "  endpoint: Docker-Host\n", "", 1))
+	result = runCommand(t, "", "render", "--importPath", "testdata/pipeline-template.yaml", "--values", missing)
+	if !result.fatal {
+		t.Error("a template with an unresolved placeholder was rendered")
+	}
+	expectOutput(t, result.logs, "Failed to render", `map has no entry for key \"endpoint\"`)
+
+	result = runCommand(t, "", "render", "--importPath", "testdata/pipeline-template.yaml")
+	expectOutput(t, result.logs, "Failed to render", `map has no entry for key \"project\"`)
+}
+
+func TestCreatePipelineFromTemplate(t *testing.T) {
+	server := newTestServer(t)
+	values := writeTestFile(t, "values.yaml", testTemplateValues)
+
+	result := runCommand(t, "", "create", "pipeline", "--importPath", "testdata/pipeline-template.yaml", "--values", values)
+	expectOutput(t, result.stdout, "Imported pipeline-template.yaml successfully - Pipeline created.")
+	pipeline := server.Find(fakeserver.Pipelines, "payments-Build", testProject)
+	if pipeline == nil {
+		t.Fatal("the rendered pipeline was not created")
+	}
+	expectOutput(t, fmt.Sprint(pipeline["workspace"]), "golang:1.17")
+
+	updated := writeTestFile(t, "values.yaml", strings.Replace(testTemplateValues, "golang:1.17", "golang:1.18", 1))
+	result = runCommand(t, "", "update", "pipeline", "--importPath", "testdata/pipeline-template.yaml", "--values", updated)
+	expectOutput(t, result.stdout, "Imported pipeline-template.yaml successfully - Pipeline updated.")
+	expectOutput(t, fmt.Sprint(server.Find(fakeserver.Pipelines, "payments-Build", testProject)["workspace"]), "golang:1.18")
+
+	missing := writeTestFile(t, "values.yaml", "service: orders\n")
+	result = runCommand(t, "", "create", "pipeline", "--importPath", "testdata/pipeline-template.yaml", "--values", missing)
+	expectOutput(t, result.logs, "Failed to import", "map has no entry for key")
+	if server.Find(fakeserver.Pipelines, "orders-Build", testProject) != nil {
+		t.Error("a pipeline with unresolved placeholders was created")
+	}
+}
@@ -5,8 +5,11 @@ SPDX-License-Identifier: BSD-2-Clause
 package cmd
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
@@ -26,8 +29,10 @@ var (
 	date              = "unknown"
 	builtBy           = "unknown"
 	// Global Flags
-	debug      bool
-	ignoreCert bool
+	debug        bool
+	ignoreCert   bool
+	targetName   string
+	insecureHTTP bool
 	// API Paging
 	count int
 	skip  int
@@ -49,19 +54,46 @@ var qParams = map[string]string{
 }
 
 type config struct {
-	domain      string
-	password    string
-	server      string
-	username    string
-	apitoken    string
-	accesstoken string
+	domain       string
+	password     string
+	server       string
+	username     string
+	apitoken     string
+	accesstoken  string
+	project      string
+	cacertfile   string
+	clientcert   string
+	clientkey    string
+	servername   string
+	proxy        string
+	targettype   string
+	region       string
+	authurl      string
+	apiurl       string
+	authmethod   string
+	clientid     string
+	clientsecret string
 }
 
+const (
+	targetTypeOnPrem = "onprem"
+	targetTypeCloud  = "cloud"
+	// defaultCSPURL is the VMware Cloud Services Platform used to exchange vRA Cloud API tokens
+	defaultCSPURL = "https://console.cloud.vmware.com"
+)
+
+// cloudServerPattern matches the vRealize Automation Cloud API hosts, e.g. api.mgmt.cloud.vmware.com
+// or de.api.mgmt.cloud.vmware.com. The first group is the region prefix (empty for US).
+var cloudServerPattern = regexp.MustCompile(`^(?:([a-z]{2})\.)?api\.mgmt\.cloud\.vmware\.com$`)
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "cs-cli",
 	Short: "CLI Interface for VMware vRealize Automation Code Stream",
 	Long:  `Command line interface for VMware vRealize Automation Code Stream`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		applyTargetDefaults(cmd)
+	},
 }
 
 // Execute is the main process
@@ -76,6 +108,8 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cs-cli.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug logging")
 	rootCmd.PersistentFlags().BoolVar(&ignoreCert, "ignoreCertificateWarnings", false, "Disable HTTPS Certificate Validation")
+	rootCmd.PersistentFlags().BoolVar(&insecureHTTP, "insecure-http", false, "Use plain HTTP instead of HTTPS, e.g. for a local test server")
+	rootCmd.PersistentFlags().StringVar(&targetName, "target", "", "Use this target for a single command, without changing the current target (or set CS_TARGET)")
 	// API Paging
 	rootCmd.PersistentFlags().IntVar(&count, "count", 100, "API Paging - Count")
 	rootCmd.PersistentFlags().IntVar(&skip, "skip", 0, "API Paging - Skip")
@@ -96,6 +130,15 @@ func initConfig() {
 	if err != nil {
 		log.Fatalln(err)
 	}
+	// CS_TARGET selects a target for this invocation only. It has to be read before
+	// AutomaticEnv is enabled, otherwise viper treats it as an override of the
+	// "target" key and hides every configured target.
+	if envTarget, ok := os.LookupEnv("CS_TARGET"); ok {
+		os.Unsetenv("CS_TARGET")
+		if targetName == "" {
+			targetName = envTarget
+		}
+	}
 	viper.SetConfigName(".cs-cli")
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(home)
@@ -108,12 +151,25 @@ func initConfig() {
 	if viper.Get("server") != nil { // CS_SERVER environment variable is set
 		log.Debugln("Using ENV variables")
 		targetConfig = config{
-			server:      sanitize.URL(viper.GetString("server")),
-			username:    viper.GetString("username"),
-			password:    viper.GetString("password"),
-			domain:      viper.GetString("domain"),
-			apitoken:    viper.GetString("apitoken"),
-			accesstoken: viper.GetString("accesstoken"),
+			server:       sanitize.URL(viper.GetString("server")),
+			username:     viper.GetString("username"),
+			password:     viper.GetString("password"),
+			domain:       viper.GetString("domain"),
+			apitoken:     viper.GetString("apitoken"),
+			accesstoken:  viper.GetString("accesstoken"),
+			project:      viper.GetString("project"),
+			cacertfile:   viper.GetString("cacertfile"),
+			clientcert:   viper.GetString("clientcert"),
+			clientkey:    viper.GetString("clientkey"),
+			servername:   viper.GetString("servername"),
+			proxy:        viper.GetString("proxy"),
+			targettype:   viper.GetString("type"),
+			region:       viper.GetString("region"),
+			authurl:      viper.GetString("authurl"),
+			apiurl:       viper.GetString("apiurl"),
+			authmethod:   viper.GetString("auth"),
+			clientid:     viper.GetString("clientid"),
+			clientsecret: viper.GetString("clientsecret"),
 		}
 	} else {
 		if cfgFile != "" { // If the user has specified a config file
@@ -134,20 +190,125 @@ func initConfig() {
 			}
 		}
 		currentTargetName = viper.GetString("currentTargetName")
+		if targetName != "" { // --target or CS_TARGET override the current target without writing the config
+			currentTargetName = targetName
+		}
 		if currentTargetName != "" {
 			log.Debugln("Using config:", viper.ConfigFileUsed(), "Target:", currentTargetName)
-			configuration := viper.Sub("target." + currentTargetName)
-			if configuration == nil { // Sub returns nil if the key cannot be found
-				log.Fatalln("Target configuration not found")
-			}
-			targetConfig = config{
-				server:      sanitize.URL(configuration.GetString("server")),
-				username:    configuration.GetString("username"),
-				password:    configuration.GetString("password"),
-				domain:      configuration.GetString("domain"),
-				apitoken:    configuration.GetString("apitoken"),
-				accesstoken: configuration.GetString("accesstoken"),
+			configuration, err := readTargetConfig(currentTargetName)
+			if err != nil {
+				log.Fatalln(err)
 			}
+			targetConfig = configuration
+		}
+	}
+	// Targets created before the type was stored are detected from the server name
+	if targetConfig.targettype == "" {
+		targetConfig.targettype, targetConfig.region = detectTargetType(targetConfig.server)
+	}
+}
+
+// readTargetConfig - returns the configuration of a target in the config file
+func readTargetConfig(name string) (config, error) {
+	configuration := viper.Sub("target." + name)
+	if configuration == nil { // Sub returns nil if the key cannot be found
+		return config{}, errors.New("Target configuration not found")
+	}
+	c := config{
+		server:       sanitize.URL(configuration.GetString("server")),
+		username:     configuration.GetString("username"),
+		password:     configuration.GetString("password"),
+		domain:       configuration.GetString("domain"),
+		apitoken:     configuration.GetString("apitoken"),
+		accesstoken:  configuration.GetString("accesstoken"),
+		project:      configuration.GetString("project"),
+		cacertfile:   configuration.GetString("cacertfile"),
+		clientcert:   configuration.GetString("clientcert"),
+		clientkey:    configuration.GetString("clientkey"),
+		servername:   configuration.GetString("servername"),
+		proxy:        configuration.GetString("proxy"),
+		targettype:   configuration.GetString("type"),
+		region:       configuration.GetString("region"),
+		authurl:      configuration.GetString("authurl"),
+		apiurl:       configuration.GetString("apiurl"),
+		authmethod:   configuration.GetString("auth"),
+		clientid:     configuration.GetString("clientid"),
+		clientsecret: configuration.GetString("clientsecret"),
+	}
+	if c.targettype == "" {
+		c.targettype, c.region = detectTargetType(c.server)
+	}
+	return c, nil
+}
+
+// detectTargetType - returns the target type and region for a server name
+func detectTargetType(server string) (string, string) {
+	match := cloudServerPattern.FindStringSubmatch(strings.ToLower(server))
+	if match == nil {
+		return targetTypeOnPrem, ""
+	}
+	if match[1] == "" {
+		return targetTypeCloud, "us"
+	}
+	return targetTypeCloud, match[1]
+}
+
+// cloudServer - returns the vRealize Automation Cloud API host for a region
+func cloudServer(region string) string {
+	if region == "" || strings.EqualFold(region, "us") {
+		return "api.mgmt.cloud.vmware.com"
+	}
+	return strings.ToLower(region) + ".api.mgmt.cloud.vmware.com"
+}
+
+// isCloud - returns true if the target is vRealize Automation Cloud
+func (c config) isCloud() bool {
+	return c.targettype == targetTypeCloud
+}
+
+// baseURL - returns the URL the Code Stream APIs are called on
+func (c config) baseURL() string {
+	if c.apiurl != "" {
+		return strings.TrimSuffix(c.apiurl, "/")
+	}
+	scheme := "https://"
+	if insecureHTTP {
+		scheme = "http://"
+	}
+	if c.server == "" && c.isCloud() {
+		return scheme + cloudServer(c.region)
+	}
+	return scheme + c.server
+}
+
+// cspURL - returns the URL API tokens are exchanged on
+func (c config) cspURL() string {
+	if c.authurl != "" {
+		return strings.TrimSuffix(c.authurl, "/")
+	}
+	if c.isCloud() {
+		return defaultCSPURL
+	}
+	return c.baseURL()
+}
+
+// applyTargetDefaults - use the target's default project when --project is not specified.
+// Only the commands that read, search, or create a single object, use the default: a default
+// must never select the objects a delete, prune, audit, apply or bulk update works on, nor
+// override the project defined in an imported file.
+func applyTargetDefaults(cmd *cobra.Command) {
+	if targetConfig.project == "" || project != "" || id != "" || importPath != "" {
+		return
+	}
+	if cmd != searchCmd {
+		switch cmd.Parent() {
+		case getCmd, reportCmd, watchCmd, describeCmd, copyCmd, createCmd:
+		default:
+			return
 		}
 	}
+	if flag := cmd.Flags().Lookup("project"); flag != nil && !flag.Changed {
+		log.Debugln("Using default project:", targetConfig.project)
+		project = targetConfig.project
+	}
 }
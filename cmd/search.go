@@ -0,0 +1,183 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var query string
+var searchScopes []string
+
+// searchScopeNames - the parts of a pipeline --in can search
+var searchScopeNames = []string{"tasks", "inputs", "workspace"}
+
+// pipelineSearchMatch - a line of a pipeline that matched the search query
+type pipelineSearchMatch struct {
+	Pipeline string `json:"pipeline"`
+	Project  string `json:"project"`
+	Stage    string `json:"stage,omitempty"`
+	Task     string `json:"task,omitempty"`
+	Field    string `json:"field"`
+	Line     int    `json:"line,omitempty"`
+	Text     string `json:"text"`
+}
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search",
+	Short: "Search the content of Pipelines",
+	Long: `Search the tasks (inputs, scripts, endpoints and preconditions), input parameters and workspace
+settings of every Pipeline for a regular expression, and print where each match was found.
+
+# Which pipelines call this script?
+cs-cli search --project "Field Demo" --query 'deploy\.sh'
+# Which tasks use the K8s-prod endpoint?
+cs-cli search --query '^K8s-prod$' --in tasks
+# Case insensitive search of the workspace settings
+cs-cli search --query '(?i)docker' --in workspace`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		for _, scope := range searchScopes {
+			if !containsFold(searchScopeNames, scope) {
+				return errors.New("--in is not valid, must be " + strings.Join(searchScopeNames, ", "))
+			}
+		}
+		return validateOutputFormat(outputFormat)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		pattern, err := regexp.Compile(query)
+		if err != nil {
+			log.Fatalln("--query is not a valid regular expression:", err)
+		}
+		if err := ensureTargetConnection(); err != nil {
+			log.Fatalln(err)
+		}
+		var filters []string
+		if project != "" {
+			filters = append(filters, "(project eq '"+project+"')")
+		}
+		pipelines, err := getAllPipelines(filters)
+		if err != nil {
+			log.Fatalln("Unable to get Code Stream Pipelines: ", err)
+		}
+		scopes := searchScopes
+		if len(scopes) == 0 {
+			scopes = searchScopeNames
+		}
+		matches := []pipelineSearchMatch{}
+		matched := 0
+		for _, pipeline := range pipelines {
+			found := searchPipeline(pipeline, pattern, scopes)
+			if len(found) > 0 {
+				matched++
+			}
+			matches = append(matches, found...)
+		}
+		if strings.EqualFold(outputFormat, "json") {
+			PrettyPrint(matches)
+			return
+		}
+		log.Infoln(len(matches), "matches in", matched, "of", len(pipelines), "Pipelines")
+		if len(matches) == 0 {
+			return
+		}
+		var rows [][]string
+		for _, m := range matches {
+			field := m.Field
+			if m.Line > 0 {
+				field = fmt.Sprintf("%s:%d", m.Field, m.Line)
+			}
+			rows = append(rows, []string{m.Pipeline, m.Project, m.Stage, m.Task, field, m.Text})
+		}
+		if err := PrintRows(outputFormat, []string{"Pipeline", "Project", "Stage", "Task", "Field", "Match"}, rows); err != nil {
+			log.Fatalln(err)
+		}
+	},
+}
+
+// searchPipeline - the lines of the pipeline's tasks, inputs and/or workspace settings matching the pattern
+func searchPipeline(pipeline *CodeStreamPipeline, pattern *regexp.Regexp, scopes []string) []pipelineSearchMatch {
+	var matches []pipelineSearchMatch
+	match := func(stage, task string) func(field string, line int, text string) {
+		return func(field string, line int, text string) {
+			matches = append(matches, pipelineSearchMatch{Pipeline: pipeline.Name, Project: pipeline.Project, Stage: stage, Task: task, Field: field, Line: line, Text: text})
+		}
+	}
+	if containsFold(scopes, "inputs") {
+		searchValue(pipeline.Input, "input", pattern, match("", ""))
+	}
+	if containsFold(scopes, "workspace") {
+		var workspace map[string]interface{}
+		workspaceBytes, _ := json.Marshal(pipeline.Workspace)
+		json.Unmarshal(workspaceBytes, &workspace)
+		searchValue(workspace, "workspace", pattern, match("", ""))
+	}
+	if containsFold(scopes, "tasks") {
+		for _, stage := range pipelineStages(pipeline) {
+			for _, group := range stage.Groups {
+				for _, task := range group {
+					searchValue(task.Definition, "", pattern, match(stage.Name, task.Name))
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// searchValue - calls found with each line of the value, at the dotted path, matching the pattern.
+// Line numbers are only given for values of more than one line.
+func searchValue(value interface{}, path string, pattern *regexp.Regexp, found func(field string, line int, text string)) {
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	switch typed := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		var keys []string
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			searchValue(typed[key], join(key), pattern, found)
+		}
+	case []interface{}:
+		for i, item := range typed {
+			searchValue(item, join(fmt.Sprint(i)), pattern, found)
+		}
+	default:
+		lines := strings.Split(fmt.Sprint(typed), "\n")
+		for i, line := range lines {
+			if !pattern.MatchString(line) {
+				continue
+			}
+			if len(lines) == 1 {
+				found(path, 0, strings.TrimSpace(line))
+			} else {
+				found(path, i+1, strings.TrimSpace(line))
+			}
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	searchCmd.Flags().StringVarP(&project, "project", "p", "", "Search the Pipelines in this Project")
+	searchCmd.Flags().StringVarP(&query, "query", "q", "", "Regular expression to search for")
+	searchCmd.Flags().StringSliceVarP(&searchScopes, "in", "", []string{}, "Only search "+strings.Join(searchScopeNames, ", ")+" (can be repeated, default is all)")
+	searchCmd.Flags().StringVarP(&outputFormat, "output", "o", "table", "Output format (table|csv|json|markdown)")
+	searchCmd.MarkFlagRequired("query")
+}
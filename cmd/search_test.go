@@ -0,0 +1,56 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vmware/code-stream-cli/internal/fakeserver"
+)
+
+func TestSearchPipelines(t *testing.T) {
+	server := newTestServer(t)
+	server.AddProject("Production")
+	server.Add(fakeserver.Pipelines, testFlowPipeline())
+	build := testPipeline("Build-App")
+	build["stages"].(map[string]interface{})["Build"].(map[string]interface{})["tasks"].(map[string]interface{})["Deploy"].(map[string]interface{})["input"] = map[string]interface{}{
+		"script": "set -e\n./scripts/deploy.sh --env ${input.environment}\necho done",
+	}
+	server.Add(fakeserver.Pipelines, build)
+	other := testPipeline("Other-App")
+	other["project"] = "Production"
+	other["workspace"] = map[string]interface{}{"endpoint": "K8s-prod", "image": "alpine"}
+	server.Add(fakeserver.Pipelines, other)
+
+	result := runCommand(t, "", "search", "--project", testProject, "--query", `deploy\.sh`, "--count", "1")
+	expectOutput(t, result.logs, "1 matches in 1 of 2 Pipelines")
+	expectOutput(t, result.stdout, "Build-App", "Build", "Deploy", "input.script:2", "./scripts/deploy.sh --env")
+
+	result = runCommand(t, "", "search", "--query", "^SSH-Host$", "--in", "tasks", "--output", "csv")
+	expectOutput(t, result.stdout,
+		"Pipeline,Project,Stage,Task,Field,Match\n",
+		"Build-App,Field Demo,Build,Deploy,endpoints.agent,SSH-Host\n",
+		"Other-App,Production,Build,Deploy,endpoints.agent,SSH-Host\n",
+		"Release-App,Field Demo,Build,Deploy,endpoints.agent,SSH-Host\n")
+
+	result = runCommand(t, "", "search", "--query", "K8s-prod", "--in", "workspace", "--output", "json")
+	expectOutput(t, result.stdout, `"pipeline": "Other-App"`, `"field": "workspace.endpoint"`, `"text": "K8s-prod"`)
+
+	result = runCommand(t, "", "search", "--query", "Run-Tests", "--in", "inputs")
+	expectOutput(t, result.logs, "0 matches in 0 of 3 Pipelines")
+	result = runCommand(t, "", "search", "--query", "Run-Tests")
+	expectOutput(t, result.stdout, "Release", "Smoke Tests", "input.pipeline")
+	if strings.Contains(result.stdout, "Build-App") {
+		t.Error("a pipeline that does not match was listed")
+	}
+
+	result = runCommand(t, "", "search", "--query", "(unclosed")
+	expectOutput(t, result.logs, "--query is not a valid regular expression")
+	result = runCommand(t, "", "search", "--query", "x", "--in", "stages")
+	if result.err == nil {
+		t.Error("an unknown --in was accepted")
+	}
+}
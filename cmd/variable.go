@@ -5,7 +5,12 @@ SPDX-License-Identifier: BSD-2-Clause
 package cmd
 
 import (
+	"errors"
+	"io/ioutil"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
@@ -13,6 +18,13 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var valueStdin bool
+var valueFile string
+var valueEnv string
+var secretPlaceholders bool
+var secretsPath string
+var prune bool
+
 // getVariableCmd represents the variable command
 var getVariableCmd = &cobra.Command{
 	Use:   "variable",
@@ -26,7 +38,12 @@ cs-cli get variable --id 6b7936d3-a19d-4298-897a-65e9dc6620c8
 cs-cli get variable --name my-variable
 	
 # Get Variable by Project
-cs-cli get variable --project production`,
+cs-cli get variable --project production
+
+# Export Variables, with placeholders such as ${secret.my-variable} in place of SECRET and RESTRICTED values
+cs-cli get variable --project production --exportPath variables.yaml --secret-placeholders
+
+SECRET and RESTRICTED values are masked in the output.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := ensureTargetConnection(); err != nil {
 			log.Fatalln(err)
@@ -42,10 +59,10 @@ cs-cli get variable --project production`,
 			log.Warnln("No results found")
 		} else if resultCount == 1 {
 			// Print the single result
-			if exportPath != "" {
+			if exportPath != "" && id != "" { // getVariable exports the variables it finds by name or project
 				exportVariable(response[0], exportPath)
 			}
-			PrettyPrint(response[0])
+			PrettyPrint(maskVariable(response[0]))
 		} else {
 			// Print result table
 			table := tablewriter.NewWriter(os.Stdout)
@@ -62,14 +79,30 @@ cs-cli get variable --project production`,
 var createVariableCmd = &cobra.Command{
 	Use:   "variable",
 	Short: "Create a Variable",
-	Long:  `Create a Variable`,
+	Long: `Create a Variable
+
+# Create a SECRET Variable, reading the value from stdin, a file or an environment variable
+# to keep it out of the shell history and process list
+echo -n "$TOKEN" | cs-cli create variable --name deploy-token --project production --type SECRET --value-stdin
+cs-cli create variable --name ssh-key --project production --type SECRET --value-file id_rsa
+cs-cli create variable --name deploy-token --project production --type SECRET --value-env TOKEN
+
+# Import Variables, resolving ${secret.name} placeholders from CS_SECRET_NAME environment variables
+# or a secrets file (a YAML map of name to value)
+cs-cli create variable --importpath variables.yaml --secrets secrets.yaml`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := ensureTargetConnection(); err != nil {
 			log.Fatalln(err)
 		}
 
 		if importPath != "" { // If we are importing a file
-			variables := importVariables(importPath)
+			variables, err := importVariables(importPath)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if err := resolveSecretPlaceholders(variables, secretsPath); err != nil {
+				log.Fatalln(err)
+			}
 			for _, value := range variables {
 				if project != "" { // If the project is specified update the object
 					value.Project = project
@@ -82,11 +115,15 @@ var createVariableCmd = &cobra.Command{
 				}
 			}
 		} else {
+			value, err := variableValue(cmd)
+			if err != nil {
+				log.Fatalln(err)
+			}
 			createResponse, err := createVariable(name, description, typename, project, value)
 			if err != nil {
 				log.Errorln("Unable to create Code Stream Variable: ", err)
 			} else {
-				PrettyPrint(createResponse)
+				PrettyPrint(maskVariable(createResponse))
 			}
 		}
 	},
@@ -96,20 +133,29 @@ var createVariableCmd = &cobra.Command{
 var updateVariableCmd = &cobra.Command{
 	Use:   "variable",
 	Short: "Update a Variable",
-	Long:  `Update a Variable`,
+	Long: `Update a Variable
+
+# Update the value of a Variable from stdin, a file or an environment variable
+cs-cli update variable --id 6b7936d3-a19d-4298-897a-65e9dc6620c8 --value-env TOKEN`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := ensureTargetConnection(); err != nil {
 			log.Fatalln(err)
 		}
 
 		if importPath != "" { // If we are importing a file
-			variables := importVariables(importPath)
+			variables, err := importVariables(importPath)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if err := resolveSecretPlaceholders(variables, secretsPath); err != nil {
+				log.Fatalln(err)
+			}
 			for _, value := range variables {
-				exisitingVariable, err := getVariable("", value.Name, value.Project, "")
-				if err != nil {
+				existingVariable, err := getVariable("", value.Name, value.Project, "")
+				if err != nil || len(existingVariable) != 1 {
 					log.Errorln("Update failed - unable to find existing Code Stream Variable", value.Name, "in", value.Project)
 				} else {
-					_, err := updateVariable(exisitingVariable[0].ID, value.Name, value.Description, value.Type, value.Value)
+					_, err := updateVariable(existingVariable[0].ID, value.Name, value.Description, value.Type, value.Value)
 					if err != nil {
 						log.Errorln("Unable to update Code Stream Variable: ", err)
 					} else {
@@ -118,15 +164,201 @@ var updateVariableCmd = &cobra.Command{
 				}
 			}
 		} else { // Else we are updating using flags
+			value, err := variableValue(cmd)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if name != "" {
+				if existing, err := getVariableByID(id); err == nil && existing.Name != "" && existing.Name != name {
+					checkDependentPipelines(existing.Project, "variable", []string{existing.Name}, "renamed")
+				}
+			}
 			updateResponse, err := updateVariable(id, name, description, typename, value)
 			if err != nil {
-				log.Errorln("Unable to update Code Stream Variable: ", err)
+				log.Fatalln("Unable to update Code Stream Variable: ", err)
 			}
 			log.Infoln("Updated variable", updateResponse.Name)
 		}
 	},
 }
 
+// applyVariablesCmd represents the apply variables command
+var applyVariablesCmd = &cobra.Command{
+	Use:   "variables",
+	Short: "Create or update Variables from a YAML file",
+	Long: `Create or update every Variable in a YAML file (one document per variable, as exported by
+cs-cli get variable --exportPath), so the file can be applied again and again. Variables that
+already exist with the same description, type and value are left unchanged. SECRET and RESTRICTED
+values cannot be compared, so those variables are always updated. Each variable can only appear
+once per project.
+
+# Create or update the Variables in the file
+cs-cli apply variables -f variables.yaml
+# Apply them to another project, resolving ${secret.name} placeholders from a secrets file
+cs-cli apply variables -f variables.yaml --project Production --secrets secrets.yaml
+# Also delete the Variables in the file's projects that are not in the file (prompts for confirmation)
+cs-cli apply variables -f variables.yaml --prune`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := ensureTargetConnection(); err != nil {
+			log.Fatalln(err)
+		}
+
+		variables, err := importVariables(importPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		var invalid []string
+		seen := make(map[string]bool)
+		for i := range variables {
+			if project != "" { // If the project is specified update the object
+				variables[i].Project = project
+			}
+			if err := validateVariable(variables[i]); err != nil {
+				invalid = append(invalid, err.Error())
+			}
+			key := variables[i].Project + "/" + variables[i].Name
+			if seen[key] {
+				invalid = append(invalid, "variable "+variables[i].Name+" appears more than once in "+variables[i].Project)
+			}
+			seen[key] = true
+		}
+		if len(invalid) > 0 {
+			log.Fatalln("Nothing applied:", strings.Join(invalid, ", "))
+		}
+		if err := resolveSecretPlaceholders(variables, secretsPath); err != nil {
+			log.Fatalln(err)
+		}
+
+		// The existing variables of each project in the file, by name
+		existing := make(map[string]map[string]*CodeStreamVariableResponse)
+		var projects []string
+		for _, v := range variables {
+			if existing[v.Project] != nil {
+				continue
+			}
+			projectVariables, err := getVariable("", "", v.Project, "")
+			if err != nil {
+				log.Fatalln("Unable to get Code Stream Variables: ", err)
+			}
+			existing[v.Project] = make(map[string]*CodeStreamVariableResponse)
+			for _, e := range projectVariables {
+				existing[v.Project][e.Name] = e
+			}
+			projects = append(projects, v.Project)
+		}
+
+		// The variables to prune in each project, checked for dependent pipelines before anything is
+		// written, so a variable still in use stops the apply rather than leaving it half done
+		pruned := make(map[string][]string)
+		if prune {
+			applied := make(map[string]bool)
+			for _, v := range variables {
+				applied[v.Project+"/"+v.Name] = true
+			}
+			for _, p := range projects {
+				for name := range existing[p] {
+					if !applied[p+"/"+name] {
+						pruned[p] = append(pruned[p], name)
+					}
+				}
+				if len(pruned[p]) > 0 {
+					sort.Strings(pruned[p])
+					checkDependentPipelines(p, "variable", pruned[p], "pruned")
+				}
+			}
+		}
+
+		var created, updated, unchanged, deleted, failed int
+		for _, v := range variables {
+			e, ok := existing[v.Project][v.Name]
+			if !ok {
+				if _, err := createVariable(v.Name, v.Description, v.Type, v.Project, v.Value); err != nil {
+					log.Warnln("Unable to create variable", v.Name, "in", v.Project+":", err)
+					failed++
+					continue
+				}
+				log.Infoln("Created variable", v.Name, "in", v.Project)
+				created++
+			} else if variableChanged(e, v) {
+				if _, err := updateVariable(e.ID, v.Name, v.Description, v.Type, v.Value); err != nil {
+					log.Warnln("Unable to update variable", v.Name, "in", v.Project+":", err)
+					failed++
+					continue
+				}
+				log.Infoln("Updated variable", v.Name, "in", v.Project)
+				updated++
+			} else {
+				log.Debugln("Variable", v.Name, "in", v.Project, "is unchanged")
+				unchanged++
+			}
+		}
+
+		for _, p := range projects {
+			names := pruned[p]
+			if len(names) == 0 {
+				continue
+			}
+			if !askForConfirmation("This will delete " + strconv.Itoa(len(names)) + " variables in " + p + " that are not in " + importPath + " (" + strings.Join(names, ", ") + "), are you sure?") {
+				continue
+			}
+			for _, name := range names {
+				if _, err := deleteVariable(existing[p][name].ID); err != nil {
+					log.Warnln("Unable to delete variable", name, "in", p+":", err)
+					failed++
+					continue
+				}
+				log.Infoln("Deleted variable", name, "in", p)
+				deleted++
+			}
+		}
+
+		log.Infoln(created, "created,", updated, "updated,", unchanged, "unchanged,", deleted, "deleted")
+		if failed > 0 {
+			log.Fatalln(failed, "variables could not be applied")
+		}
+	},
+}
+
+// variableValue - the value given with --value, --value-stdin, --value-file or --value-env. A trailing
+// newline is removed from a value read from stdin or a file.
+func variableValue(cmd *cobra.Command) (string, error) {
+	var set []string
+	for _, flag := range []string{"value", "value-stdin", "value-file", "value-env"} {
+		if cmd.Flags().Changed(flag) {
+			set = append(set, "--"+flag)
+		}
+	}
+	if len(set) > 1 {
+		return "", errors.New("Only one of " + strings.Join(set, ", ") + " can be used")
+	}
+	switch {
+	case valueStdin:
+		valueBytes, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		return trimTrailingNewline(string(valueBytes)), nil
+	case valueFile != "":
+		valueBytes, err := ioutil.ReadFile(valueFile)
+		if err != nil {
+			return "", err
+		}
+		return trimTrailingNewline(string(valueBytes)), nil
+	case valueEnv != "":
+		envValue, ok := os.LookupEnv(valueEnv)
+		if !ok {
+			return "", errors.New("The environment variable " + valueEnv + " is not set")
+		}
+		return envValue, nil
+	}
+	return value, nil
+}
+
+// trimTrailingNewline - the string without one trailing newline
+func trimTrailingNewline(s string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+}
+
 // deleteVariableCmd represents the executions command
 var deleteVariableCmd = &cobra.Command{
 	Use:   "variable",
@@ -144,13 +376,30 @@ cs-cli delete variable --name "My Variable" --project "My Project"
 
 # Delete all Variables in Project
 cs-cli delete variable --project "My Project"
+
+Pipelines that use the variable(s) are listed and nothing is deleted, unless --force is given.
 	`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := ensureTargetConnection(); err != nil {
 			log.Fatalln(err)
 		}
 
+		if id == "" && name != "" {
+			variables, err := getVariable("", name, project, "")
+			if err != nil {
+				log.Fatalln("Unable to get Code Stream Variables: ", err)
+			}
+			if len(variables) != 1 {
+				log.Fatalln("Found", len(variables), "variables named", name, "- use --project or --id")
+			}
+			id = variables[0].ID
+		}
 		if id != "" {
+			variable, err := getVariableByID(id)
+			if err != nil || variable.ID == "" {
+				log.Fatalln("Unable to find variable", id)
+			}
+			checkDependentPipelines(variable.Project, "variable", []string{variable.Name}, "deleted")
 			response, err := deleteVariable(id)
 			if err != nil {
 				log.Errorln("Unable to delete variable: ", err)
@@ -158,6 +407,15 @@ cs-cli delete variable --project "My Project"
 				log.Infoln("Variable with id " + response.ID + " deleted")
 			}
 		} else if project != "" {
+			variables, err := getVariable("", "", project, "")
+			if err != nil {
+				log.Fatalln("Unable to get Code Stream Variables: ", err)
+			}
+			var names []string
+			for _, v := range variables {
+				names = append(names, v.Name)
+			}
+			checkDependentPipelines(project, "variable", names, "deleted")
 			response, err := deleteVariableByProject(project)
 			if err != nil {
 				log.Errorln("Delete Variables in "+project+" failed:", err)
@@ -175,6 +433,7 @@ func init() {
 	getVariableCmd.Flags().StringVarP(&project, "project", "p", "", "List variables in project")
 	getVariableCmd.Flags().StringVarP(&id, "id", "i", "", "List variables by id")
 	getVariableCmd.Flags().StringVarP(&exportPath, "exportPath", "", "", "Path to export objects - relative or absolute location")
+	getVariableCmd.Flags().BoolVarP(&secretPlaceholders, "secret-placeholders", "", false, "Export SECRET and RESTRICTED values as ${secret.name} placeholders")
 	// Create Variable
 	createCmd.AddCommand(createVariableCmd)
 	createVariableCmd.Flags().StringVarP(&name, "name", "n", "", "The name of the variable to create")
@@ -183,6 +442,10 @@ func init() {
 	createVariableCmd.Flags().StringVarP(&value, "value", "v", "", "The value of the variable to create")
 	createVariableCmd.Flags().StringVarP(&description, "description", "d", "", "The description of the variable to create")
 	createVariableCmd.Flags().StringVarP(&importPath, "importpath", "i", "", "Path to a YAML file with the variables to import")
+	createVariableCmd.Flags().BoolVarP(&valueStdin, "value-stdin", "", false, "Read the value of the variable from stdin")
+	createVariableCmd.Flags().StringVarP(&valueFile, "value-file", "", "", "Read the value of the variable from a file")
+	createVariableCmd.Flags().StringVarP(&valueEnv, "value-env", "", "", "Read the value of the variable from an environment variable")
+	createVariableCmd.Flags().StringVarP(&secretsPath, "secrets", "", "", "YAML file of values for the ${secret.name} placeholders in the imported variables")
 
 	// Update Variable
 	updateCmd.AddCommand(updateVariableCmd)
@@ -192,11 +455,26 @@ func init() {
 	updateVariableCmd.Flags().StringVarP(&value, "value", "v", "", "Update the value of the variable ")
 	updateVariableCmd.Flags().StringVarP(&description, "description", "d", "", "Update the description of the variable")
 	updateVariableCmd.Flags().StringVarP(&importPath, "importpath", "", "", "Path to a YAML file with the variables to import")
+	updateVariableCmd.Flags().BoolVarP(&valueStdin, "value-stdin", "", false, "Read the value of the variable from stdin")
+	updateVariableCmd.Flags().StringVarP(&valueFile, "value-file", "", "", "Read the value of the variable from a file")
+	updateVariableCmd.Flags().StringVarP(&valueEnv, "value-env", "", "", "Read the value of the variable from an environment variable")
+	updateVariableCmd.Flags().StringVarP(&secretsPath, "secrets", "", "", "YAML file of values for the ${secret.name} placeholders in the imported variables")
+	updateVariableCmd.Flags().BoolVarP(&force, "force", "", false, "Rename the variable even if Pipelines use it")
 	//updateVariableCmd.MarkFlagRequired("id")
 
 	// Delete Variable
 	deleteCmd.AddCommand(deleteVariableCmd)
 	deleteVariableCmd.Flags().StringVarP(&id, "id", "i", "", "Delete variable by id")
+	deleteVariableCmd.Flags().StringVarP(&name, "name", "n", "", "Delete variable by name")
 	deleteVariableCmd.Flags().StringVarP(&project, "project", "p", "", "The project in which to delete the variable, or delete all variables in project")
+	deleteVariableCmd.Flags().BoolVarP(&force, "force", "", false, "Delete even if Pipelines use the variable")
 
+	// Apply Variables
+	applyCmd.AddCommand(applyVariablesCmd)
+	applyVariablesCmd.Flags().StringVarP(&importPath, "filename", "f", "", "YAML file with the variables to apply")
+	applyVariablesCmd.Flags().StringVarP(&project, "project", "p", "", "Apply the variables to this project (overrides YAML)")
+	applyVariablesCmd.Flags().StringVarP(&secretsPath, "secrets", "", "", "YAML file of values for the ${secret.name} placeholders in the variables")
+	applyVariablesCmd.Flags().BoolVarP(&prune, "prune", "", false, "Delete the variables in the file's projects that are not in the file")
+	applyVariablesCmd.Flags().BoolVarP(&force, "force", "", false, "Prune variables even if Pipelines use them")
+	applyVariablesCmd.MarkFlagRequired("filename")
 }
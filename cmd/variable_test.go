@@ -0,0 +1,258 @@
+/*
+Package cmd Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware/code-stream-cli/internal/fakeserver"
+)
+
+func TestCreateGetUpdateDeleteVariable(t *testing.T) {
+	server := newTestServer(t)
+
+	result := runCommand(t, "", "create", "variable", "--name", "build-number", "--project", testProject, "--type", "REGULAR", "--value", "41", "--description", "Last build")
+	expectOutput(t, result.stdout, `"name": "build-number"`, `"value": "41"`)
+	variable := server.Find(fakeserver.Variables, "build-number", testProject)
+	if variable == nil {
+		t.Fatal("variable was not created")
+	}
+	id := variable["id"].(string)
+
+	result = runCommand(t, "", "get", "variable", "--name", "build-number")
+	expectOutput(t, result.stdout, `"description": "Last build"`)
+
+	result = runCommand(t, "", "update", "variable", "--id", id, "--value", "42")
+	expectOutput(t, result.logs, "Updated variable build-number")
+	if server.Get(fakeserver.Variables, id)["value"] != "42" {
+		t.Error("variable value was not updated")
+	}
+
+	result = runCommand(t, "", "delete", "variable", "--id", id)
+	expectOutput(t, result.logs, "Variable with id "+id+" deleted")
+	if server.Get(fakeserver.Variables, id) != nil {
+		t.Error("variable was not deleted")
+	}
+}
+
+func TestImportAndExportVariables(t *testing.T) {
+	server := newTestServer(t)
+
+	result := runCommand(t, "", "create", "variable", "--importpath", "testdata/variables.yaml")
+	expectOutput(t, result.logs, "Created variable imported-regular in "+testProject, "Created variable imported-secret in "+testProject)
+
+	result = runCommand(t, "", "get", "variable", "--project", testProject)
+	expectOutput(t, result.stdout, "imported-regular", "imported-secret", "SECRET")
+
+	exportDir := t.TempDir()
+	runCommand(t, "", "get", "variable", "--project", testProject, "--exportPath", exportDir)
+	exported, err := os.ReadFile(filepath.Join(exportDir, "variables.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOutput(t, string(exported), "name: imported-regular", "value: regular-value", "name: imported-secret")
+	if server.Find(fakeserver.Variables, "imported-secret", testProject)["value"] != "secret-value" {
+		t.Error("secret variable was not imported with its value")
+	}
+}
+
+func TestDeleteVariableInUse(t *testing.T) {
+	server := newTestServer(t)
+	server.Add(fakeserver.Pipelines, testPipeline("Build-App"))
+	other := testPipeline("Other-App")
+	other["project"] = "Production"
+	server.AddProject("Production")
+	server.Add(fakeserver.Pipelines, other)
+	id := server.Add(fakeserver.Variables, map[string]interface{}{"name": "deploy-token", "project": testProject, "type": "SECRET", "value": "s3cret"})
+	unused := server.Add(fakeserver.Variables, map[string]interface{}{"name": "unused", "project": testProject, "type": "REGULAR", "value": "a"})
+
+	result := runCommand(t, "", "delete", "variable", "--id", id)
+	if !result.fatal || server.Get(fakeserver.Variables, id) == nil {
+		t.Fatal("a variable used by a pipeline was deleted without --force")
+	}
+	expectOutput(t, result.stdout, "Build-App", "variable deploy-token")
+	expectOutput(t, result.logs, "1 Pipelines use the variable(s) to be deleted, use --force to continue anyway")
+	if strings.Contains(result.stdout, "Other-App") {
+		t.Error("a pipeline in another project was listed")
+	}
+
+	result = runCommand(t, "", "update", "variable", "--id", id, "--name", "deploy-key")
+	expectOutput(t, result.logs, "1 Pipelines use the variable(s) to be renamed")
+	if server.Get(fakeserver.Variables, id)["name"] != "deploy-token" {
+		t.Error("a variable used by a pipeline was renamed without --force")
+	}
+
+	result = runCommand(t, "", "delete", "variable", "--name", "unused", "--project", testProject)
+	expectOutput(t, result.logs, "Variable with id "+unused+" deleted")
+
+	result = runCommand(t, "n\n", "delete", "variable", "--project", testProject)
+	expectOutput(t, result.logs, "use --force to continue anyway")
+
+	result = runCommand(t, "", "delete", "variable", "--name", "deploy-token", "--project", testProject, "--force")
+	expectOutput(t, result.logs, "continuing because of --force", "Variable with id "+id+" deleted")
+}
+
+func TestVariableSecretValues(t *testing.T) {
+	server := newTestServer(t)
+
+	result := runCommand(t, "s3cret\n", "create", "variable", "--name", "deploy-token", "--project", testProject, "--type", "SECRET", "--value-stdin")
+	variable := server.Find(fakeserver.Variables, "deploy-token", testProject)
+	if variable == nil || variable["value"] != "s3cret" {
+		t.Fatalf("the variable was not created with the value from stdin: %v", variable)
+	}
+	id := variable["id"].(string)
+
+	runCommand(t, "", "create", "variable", "--name", "api-key", "--project", testProject, "--type", "RESTRICTED", "--value", "r3stricted")
+	result = runCommand(t, "", "get", "variable", "--name", "api-key")
+	expectOutput(t, result.stdout, `"value": "********"`)
+	if strings.Contains(result.stdout, "r3stricted") {
+		t.Error("a RESTRICTED value was printed")
+	}
+
+	runCommand(t, "", "update", "variable", "--id", id, "--value-file", writeTestFile(t, "token", "from-file\n"))
+	if server.Get(fakeserver.Variables, id)["value"] != "from-file" {
+		t.Error("the value was not read from --value-file")
+	}
+	os.Setenv("TEST_DEPLOY_TOKEN", "from-env")
+	defer os.Unsetenv("TEST_DEPLOY_TOKEN")
+	runCommand(t, "", "update", "variable", "--id", id, "--value-env", "TEST_DEPLOY_TOKEN")
+	if server.Get(fakeserver.Variables, id)["value"] != "from-env" {
+		t.Error("the value was not read from --value-env")
+	}
+	result = runCommand(t, "", "update", "variable", "--id", id, "--value-env", "TEST_UNSET_TOKEN")
+	expectOutput(t, result.logs, "The environment variable TEST_UNSET_TOKEN is not set")
+	result = runCommand(t, "", "update", "variable", "--id", id, "--value", "a", "--value-stdin")
+	expectOutput(t, result.logs, "Only one of --value, --value-stdin can be used")
+
+	runCommand(t, "", "create", "variable", "--name", "build-number", "--project", testProject, "--type", "REGULAR", "--value", "41")
+	exportPath := filepath.Join(t.TempDir(), "variables.yaml")
+	runCommand(t, "", "get", "variable", "--project", testProject, "--exportPath", exportPath, "--secret-placeholders")
+	exported, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOutput(t, string(exported), "value: ${secret.deploy-token}", "value: ${secret.api-key}", "value: \"41\"")
+
+	server.AddProject("Production")
+	productionPath := writeTestFile(t, "variables.yaml", strings.ReplaceAll(string(exported), "Field Demo", "Production"))
+	result = runCommand(t, "", "create", "variable", "--importpath", productionPath)
+	expectOutput(t, result.logs, "No value for secret placeholder(s)", "api-key (CS_SECRET_API_KEY)", "deploy-token (CS_SECRET_DEPLOY_TOKEN)")
+	if server.Find(fakeserver.Variables, "build-number", "Production") != nil {
+		t.Error("variables were imported with an unresolved placeholder")
+	}
+
+	secretsPath := writeTestFile(t, "secrets.yaml", "deploy-token: from-secrets-file\napi-key: key\n")
+	result = runCommand(t, "", "create", "variable", "--importpath", productionPath, "--secrets", secretsPath)
+	expectOutput(t, result.logs, "Created variable deploy-token in Production")
+	if server.Find(fakeserver.Variables, "deploy-token", "Production")["value"] != "from-secrets-file" {
+		t.Error("the placeholder was not resolved from the secrets file")
+	}
+
+	os.Setenv("CS_SECRET_DEPLOY_TOKEN", "from-secret-env")
+	defer os.Unsetenv("CS_SECRET_DEPLOY_TOKEN")
+	runCommand(t, "", "update", "variable", "--importpath", productionPath, "--secrets", secretsPath)
+	if server.Find(fakeserver.Variables, "deploy-token", "Production")["value"] != "from-secret-env" {
+		t.Error("the placeholder was not resolved from the environment")
+	}
+}
+
+func TestApplyVariables(t *testing.T) {
+	server := newTestServer(t)
+	server.Add(fakeserver.Pipelines, testPipeline("Build-App"))
+	server.Add(fakeserver.Variables, map[string]interface{}{"name": "build-number", "project": testProject, "type": "REGULAR", "value": "41", "description": "Last build"})
+	server.Add(fakeserver.Variables, map[string]interface{}{"name": "region", "project": testProject, "type": "REGULAR", "value": "eu-west-1"})
+	server.Add(fakeserver.Variables, map[string]interface{}{"name": "deploy-token", "project": testProject, "type": "SECRET", "value": "s3cret"})
+	server.Add(fakeserver.Variables, map[string]interface{}{"name": "old", "project": testProject, "type": "REGULAR", "value": "a"})
+	path := writeTestFile(t, "variables.yaml", `---
+project: Field Demo
+kind: VARIABLE
+name: build-number
+description: Last build
+type: REGULAR
+value: "41"
+---
+project: Field Demo
+kind: VARIABLE
+name: region
+type: REGULAR
+value: us-east-1
+---
+project: Field Demo
+kind: VARIABLE
+name: image
+type: RESTRICTED
+value: alpine
+`)
+
+	result := runCommand(t, "y\n", "apply", "variables", "-f", path)
+	expectOutput(t, result.logs, "Created variable image in Field Demo", "Updated variable region in Field Demo", "1 created, 1 updated, 1 unchanged, 0 deleted")
+	if server.Find(fakeserver.Variables, "region", testProject)["value"] != "us-east-1" {
+		t.Error("the existing variable was not updated")
+	}
+
+	result = runCommand(t, "y\n", "apply", "variables", "-f", path, "--prune")
+	if !result.fatal || server.Find(fakeserver.Variables, "deploy-token", testProject) == nil {
+		t.Fatal("a variable used by a pipeline was pruned without --force")
+	}
+	expectOutput(t, result.logs, "1 Pipelines use the variable(s) to be pruned")
+	if strings.Contains(result.logs, "Updated variable image") {
+		t.Error("variables were applied before the prune was stopped")
+	}
+
+	result = runCommand(t, "y\n", "apply", "variables", "-f", path, "--prune", "--force")
+	expectOutput(t, result.logs, "Deleted variable deploy-token in Field Demo", "Deleted variable old in Field Demo", "0 created, 1 updated, 2 unchanged, 2 deleted")
+	if server.Find(fakeserver.Variables, "old", testProject) != nil {
+		t.Error("the variable not in the file was not pruned")
+	}
+
+	server.AddProject("Production")
+	result = runCommand(t, "", "apply", "variables", "-f", path, "--project", "Production")
+	expectOutput(t, result.logs, "3 created, 0 updated, 0 unchanged, 0 deleted")
+
+	invalid := writeTestFile(t, "invalid.yaml", "---\nproject: Field Demo\nname: a\ntype: PLAIN\nvalue: x\n---\nname: b\ntype: REGULAR\n---\nproject: Field Demo\nname: c\ntype: REGULAR\n---\nproject: Field Demo\nname: c\ntype: REGULAR\n")
+	result = runCommand(t, "", "apply", "variables", "-f", invalid)
+	expectOutput(t, result.logs, "Nothing applied:", "variable a has type 'PLAIN', it must be one of REGULAR, SECRET, RESTRICTED", "variable b has no project", "variable c appears more than once in Field Demo")
+	if server.Find(fakeserver.Variables, "a", testProject) != nil {
+		t.Error("an invalid variable was applied")
+	}
+
+	// Existing variables are found beyond the first page of results
+	server.AddProject("Staging")
+	for i := 0; i < 120; i++ {
+		server.Add(fakeserver.Variables, map[string]interface{}{"name": fmt.Sprintf("var-%03d", i), "project": "Staging", "type": "REGULAR", "value": "a"})
+	}
+	result = runCommand(t, "", "apply", "variables", "-f", writeTestFile(t, "staging.yaml", "project: Staging\nname: var-110\ntype: REGULAR\nvalue: a\n"))
+	expectOutput(t, result.logs, "0 created, 0 updated, 1 unchanged, 0 deleted")
+
+	result = runCommand(t, "", "update", "variable", "--importpath", writeTestFile(t, "missing.yaml", "project: Field Demo\nname: missing\ntype: REGULAR\n"))
+	expectOutput(t, result.logs, "Update failed - unable to find existing Code Stream Variable missing in Field Demo")
+}
+
+func TestVariableUsedOutsideTasks(t *testing.T) {
+	server := newTestServer(t)
+	pipeline := testPipeline("Build-App")
+	pipeline["input"] = map[string]interface{}{"environment": "dev", "tag": "${var.image-tag}"}
+	pipeline["workspace"] = map[string]interface{}{"endpoint": "Docker-Host", "image": "registry/${var.builder-image}"}
+	server.Add(fakeserver.Pipelines, pipeline)
+	id := server.Add(fakeserver.Variables, map[string]interface{}{"name": "image-tag", "project": testProject, "type": "REGULAR", "value": "latest"})
+	server.Add(fakeserver.Variables, map[string]interface{}{"name": "builder-image", "project": testProject, "type": "REGULAR", "value": "golang"})
+
+	result := runCommand(t, "", "delete", "variable", "--id", id)
+	if !result.fatal || server.Get(fakeserver.Variables, id) == nil {
+		t.Fatal("a variable used in a pipeline input default was deleted without --force")
+	}
+	expectOutput(t, result.stdout, "Build-App", "variable image-tag")
+
+	result = runCommand(t, "", "audit", "unused", "--project", testProject)
+	for _, used := range []string{"image-tag", "builder-image"} {
+		if strings.Contains(result.stdout, used) {
+			t.Errorf("%s is used by the pipeline but was listed as unused", used)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Package main Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+
+// cs-fakeserver runs the in-memory Code Stream API for offline demos:
+//
+//	go run ./internal/fakeserver/cs-fakeserver --listen localhost:8080
+//	CS_SERVER=localhost:8080 CS_USERNAME=demo CS_PASSWORD=demo cs-cli --insecure-http get pipeline
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/vmware/code-stream-cli/internal/fakeserver"
+)
+
+func main() {
+	listen := flag.String("listen", "localhost:8080", "Address to listen on")
+	username := flag.String("username", "demo", "Username accepted by the login API")
+	password := flag.String("password", "demo", "Password accepted by the login API")
+	empty := flag.Bool("empty", false, "Start without the demo project and pipeline")
+	flag.Parse()
+
+	server := fakeserver.New(*username, *password)
+	if !*empty {
+		seed(server)
+	}
+	log.Println("Fake Code Stream API listening on http://" + *listen)
+	log.Fatal(http.ListenAndServe(*listen, server))
+}
+
+// seed - adds a demo project with a pipeline, variable and endpoint
+func seed(server *fakeserver.Server) {
+	server.AddProject("Demo")
+	server.Add(fakeserver.Endpoints, map[string]interface{}{
+		"project": "Demo",
+		"kind":    "ENDPOINT",
+		"name":    "Demo-Git",
+		"type":    "git",
+		"properties": map[string]interface{}{
+			"serverType": "GitHub",
+			"repoURL":    "https://github.com/vmware/code-stream-cli",
+			"branch":     "main",
+		},
+	})
+	server.Add(fakeserver.Variables, map[string]interface{}{
+		"project": "Demo",
+		"kind":    "VARIABLE",
+		"name":    "demo-greeting",
+		"type":    "REGULAR",
+		"value":   "Hello from cs-fakeserver",
+	})
+	server.Add(fakeserver.Pipelines, map[string]interface{}{
+		"project":     "Demo",
+		"kind":        "PIPELINE",
+		"name":        "Demo-Pipeline",
+		"description": "A pipeline served by cs-fakeserver",
+		"enabled":     true,
+		"state":       "ENABLED",
+		"concurrency": 10,
+		"input":       map[string]interface{}{"environment": "dev"},
+		"stageOrder":  []interface{}{"Build"},
+		"stages": map[string]interface{}{
+			"Build": map[string]interface{}{
+				"taskOrder": []interface{}{"Greet"},
+				"tasks": map[string]interface{}{
+					"Greet": map[string]interface{}{
+						"type":      "SSH",
+						"endpoints": map[string]interface{}{"agent": "Demo-Git"},
+						"input": map[string]interface{}{
+							"script": "echo ${var.demo-greeting} to ${input.environment}",
+						},
+					},
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,311 @@
+/*
+Package fakeserver Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package fakeserver
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// filterExpression - a parsed OData $filter, evaluated against a document
+type filterExpression func(document map[string]interface{}) bool
+
+// parseFilter - parses the subset of OData used by the Code Stream APIs:
+// eq, ne, gt, ge, lt, le combined with and, or, not and parentheses.
+// A path ending in ".item" matches any element of an array, e.g. tags.item eq 'x'
+func parseFilter(filter string) (filterExpression, error) {
+	if strings.TrimSpace(filter) == "" {
+		return func(map[string]interface{}) bool { return true }, nil
+	}
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	expression, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.position < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in $filter", p.tokens[p.position].text)
+	}
+	return expression, nil
+}
+
+type filterToken struct {
+	text   string
+	quoted bool
+}
+
+func tokenizeFilter(filter string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(filter)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, filterToken{text: string(r)})
+			i++
+		case r == '\'':
+			// Quoted string, '' is an escaped quote
+			var value strings.Builder
+			i++
+			for {
+				if i >= len(runes) {
+					return nil, errors.New("unterminated string in $filter")
+				}
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						value.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				value.WriteRune(runes[i])
+				i++
+			}
+			tokens = append(tokens, filterToken{text: value.String(), quoted: true})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != '\'' {
+				i++
+			}
+			tokens = append(tokens, filterToken{text: string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens   []filterToken
+	position int
+}
+
+func (p *filterParser) peek() (filterToken, bool) {
+	if p.position >= len(p.tokens) {
+		return filterToken{}, false
+	}
+	return p.tokens[p.position], true
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	token, ok := p.peek()
+	if !ok {
+		return token, errors.New("unexpected end of $filter")
+	}
+	p.position++
+	return token, nil
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	token, ok := p.peek()
+	return ok && !token.quoted && strings.EqualFold(token.text, keyword)
+}
+
+func (p *filterParser) parseOr() (filterExpression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("or") {
+		p.position++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l, r := left, right
+		left = func(d map[string]interface{}) bool { return l(d) || r(d) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterExpression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("and") {
+		p.position++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l, r := left, right
+		left = func(d map[string]interface{}) bool { return l(d) && r(d) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterExpression, error) {
+	if p.peekKeyword("not") {
+		p.position++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(d map[string]interface{}) bool { return !operand(d) }, nil
+	}
+	if token, ok := p.peek(); ok && !token.quoted && token.text == "(" {
+		p.position++
+		expression, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, err := p.next(); err != nil || closing.text != ")" {
+			return nil, errors.New("missing ) in $filter")
+		}
+		return expression, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterExpression, error) {
+	field, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	operator, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	op := strings.ToLower(operator.text)
+	switch op {
+	case "eq", "ne", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("unsupported operator %q in $filter", operator.text)
+	}
+	path := field.text
+	return func(d map[string]interface{}) bool {
+		for _, fieldValue := range lookupPath(d, path) {
+			if compareValues(fieldValue, op, value.text) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// lookupPath - returns the values at a dotted path, expanding arrays for ".item"
+func lookupPath(document map[string]interface{}, path string) []interface{} {
+	values := []interface{}{document}
+	for _, part := range strings.Split(path, ".") {
+		var nextValues []interface{}
+		for _, v := range values {
+			switch typed := v.(type) {
+			case map[string]interface{}:
+				if child, ok := typed[part]; ok {
+					nextValues = append(nextValues, child)
+				}
+			case []interface{}:
+				if part == "item" {
+					nextValues = append(nextValues, typed...)
+				}
+			case []string:
+				if part == "item" {
+					for _, s := range typed {
+						nextValues = append(nextValues, s)
+					}
+				}
+			}
+		}
+		values = nextValues
+	}
+	return values
+}
+
+func compareValues(fieldValue interface{}, op, value string) bool {
+	fieldString := stringValue(fieldValue)
+	fieldNumber, fieldErr := strconv.ParseFloat(fieldString, 64)
+	valueNumber, valueErr := strconv.ParseFloat(value, 64)
+	var cmp int
+	if fieldErr == nil && valueErr == nil {
+		switch {
+		case fieldNumber < valueNumber:
+			cmp = -1
+		case fieldNumber > valueNumber:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(fieldString, value)
+	}
+	switch op {
+	case "eq":
+		return cmp == 0
+	case "ne":
+		return cmp != 0
+	case "gt":
+		return cmp > 0
+	case "ge":
+		return cmp >= 0
+	case "lt":
+		return cmp < 0
+	}
+	return cmp <= 0
+}
+
+func stringValue(v interface{}) string {
+	switch typed := v.(type) {
+	case string:
+		return typed
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// sortDocuments - sorts documents by an OData $orderby, e.g. "_requestTimeInMicros desc"
+func sortDocuments(documents []map[string]interface{}, orderBy string) {
+	if strings.TrimSpace(orderBy) == "" {
+		return
+	}
+	type sortKey struct {
+		path       string
+		descending bool
+	}
+	var keys []sortKey
+	for _, clause := range strings.Split(orderBy, ",") {
+		fields := strings.Fields(clause)
+		if len(fields) == 0 {
+			continue
+		}
+		keys = append(keys, sortKey{path: fields[0], descending: len(fields) > 1 && strings.EqualFold(fields[1], "desc")})
+	}
+	sort.SliceStable(documents, func(i, j int) bool {
+		for _, key := range keys {
+			a, b := firstValue(documents[i], key.path), firstValue(documents[j], key.path)
+			if compareValues(a, "eq", stringValue(b)) {
+				continue
+			}
+			less := compareValues(a, "lt", stringValue(b))
+			if key.descending {
+				return !less
+			}
+			return less
+		}
+		return false
+	})
+}
+
+func firstValue(document map[string]interface{}, path string) interface{} {
+	values := lookupPath(document, path)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
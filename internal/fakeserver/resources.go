@@ -0,0 +1,452 @@
+/*
+Package fakeserver Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+package fakeserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// collection - list (GET) or create (POST) documents
+func (s *Server) collection(w http.ResponseWriter, r *http.Request, collection string) {
+	switch r.Method {
+	case http.MethodGet:
+		s.list(w, r, collection)
+	case http.MethodPost:
+		if collection != Variables {
+			writeJSON(w, http.StatusMethodNotAllowed, exception(http.StatusMethodNotAllowed, "Use /pipeline/api/import to create "+collection, r.URL.Path))
+			return
+		}
+		s.createVariable(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, exception(http.StatusMethodNotAllowed, "Method Not Allowed", r.URL.Path))
+	}
+}
+
+// list - a Code Stream documents list, supporting $filter, $orderby, $top and $skip
+func (s *Server) list(w http.ResponseWriter, r *http.Request, collection string) {
+	query := r.URL.Query()
+	filter, err := parseFilter(query.Get("$filter"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, exception(http.StatusBadRequest, err.Error(), r.URL.Path))
+		return
+	}
+	var matches []map[string]interface{}
+	for _, id := range s.order[collection] {
+		document := s.documents[collection][id]
+		if filter(document) {
+			matches = append(matches, document)
+		}
+	}
+	sortDocuments(matches, query.Get("$orderby"))
+
+	total := len(matches)
+	skip := intParam(r, "$skip", 0)
+	top := intParam(r, "$top", 100)
+	if skip > len(matches) {
+		skip = len(matches)
+	}
+	matches = matches[skip:]
+	if top >= 0 && top < len(matches) {
+		matches = matches[:top]
+	}
+
+	links := []string{}
+	documents := map[string]interface{}{}
+	for _, document := range matches {
+		link := document["_link"].(string)
+		links = append(links, link)
+		documents[link] = responseDocument(collection, document)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":      len(links),
+		"totalCount": total,
+		"links":      links,
+		"documents":  documents,
+	})
+}
+
+// document - GET, PUT, PATCH or DELETE a single document
+func (s *Server) document(w http.ResponseWriter, r *http.Request, collection, id string) {
+	document, ok := s.documents[collection][id]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, exception(http.StatusNotFound, fmt.Sprintf("Document with id %s not found", id), r.URL.Path))
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		response := responseDocument(collection, document)
+		if polls, running := s.running[id]; running && collection == Executions {
+			response["status"] = "RUNNING"
+			response["statusMessage"] = "Execution Running."
+			if polls <= 1 {
+				delete(s.running, id)
+			} else {
+				s.running[id] = polls - 1
+			}
+		}
+		writeJSON(w, http.StatusOK, response)
+	case http.MethodDelete:
+		delete(s.running, id)
+		s.remove(collection, id)
+		writeJSON(w, http.StatusOK, responseDocument(collection, document))
+	case http.MethodPut, http.MethodPatch:
+		var changes map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			writeJSON(w, http.StatusBadRequest, exception(http.StatusBadRequest, err.Error(), r.URL.Path))
+			return
+		}
+		if collection == Variables {
+			if message := validateVariable(changes); message != "" {
+				writeJSON(w, http.StatusBadRequest, exception(http.StatusBadRequest, message, r.URL.Path))
+				return
+			}
+		}
+		for _, key := range []string{"id", "_link", "_createTimeInMicros"} {
+			delete(changes, key)
+		}
+		for key, value := range changes {
+			document[key] = value
+		}
+		if state, ok := changes["state"].(string); ok && collection == Pipelines {
+			document["state"] = strings.ToUpper(state)
+			document["enabled"] = !strings.EqualFold(state, "DISABLED")
+		}
+		document["_updateTimeInMicros"] = s.now()
+		writeJSON(w, http.StatusOK, responseDocument(collection, document))
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, exception(http.StatusMethodNotAllowed, "Method Not Allowed", r.URL.Path))
+	}
+}
+
+// executionLogs - GET /codestream/api/executions/<id>/logs, the logs set with SetExecutionLogs
+func (s *Server) executionLogs(w http.ResponseWriter, r *http.Request, id string) {
+	logs, ok := s.logs[id]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, exception(http.StatusNotFound, fmt.Sprintf("No logs for execution %s", id), r.URL.Path))
+		return
+	}
+	writeJSON(w, http.StatusOK, logs)
+}
+
+// responseDocument - the document as returned by the API, SECRET variable values are never returned
+func responseDocument(collection string, document map[string]interface{}) map[string]interface{} {
+	response := copyDocument(document)
+	if collection == Variables && response["type"] == "SECRET" {
+		response["value"] = ""
+	}
+	return response
+}
+
+func validateVariable(variable map[string]interface{}) string {
+	switch variable["type"] {
+	case "REGULAR", "SECRET", "RESTRICTED":
+		return ""
+	}
+	return fmt.Sprintf("Invalid variable type '%v', must be one of REGULAR, SECRET, RESTRICTED", variable["type"])
+}
+
+func (s *Server) createVariable(w http.ResponseWriter, r *http.Request) {
+	var variable map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&variable); err != nil {
+		writeJSON(w, http.StatusBadRequest, exception(http.StatusBadRequest, err.Error(), r.URL.Path))
+		return
+	}
+	name, _ := variable["name"].(string)
+	project, _ := variable["project"].(string)
+	if message := validateVariable(variable); message != "" {
+		writeJSON(w, http.StatusBadRequest, exception(http.StatusBadRequest, message, r.URL.Path))
+		return
+	}
+	if s.projectID(project) == "" {
+		writeJSON(w, http.StatusBadRequest, exception(http.StatusBadRequest, "Project '"+project+"' does not exist", r.URL.Path))
+		return
+	}
+	if s.find(Variables, name, project) != nil {
+		writeJSON(w, http.StatusConflict, exception(http.StatusConflict, "Variable with name '"+name+"' already exists in project '"+project+"'", r.URL.Path))
+		return
+	}
+	variable["kind"] = "VARIABLE"
+	delete(variable, "id")
+	s.add(Variables, variable)
+	writeJSON(w, http.StatusOK, responseDocument(Variables, variable))
+}
+
+// createExecution - POST /pipeline/api/pipelines/{id}/executions
+func (s *Server) createExecution(w http.ResponseWriter, r *http.Request, pipelineID string) {
+	pipeline, ok := s.documents[Pipelines][pipelineID]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, exception(http.StatusNotFound, "Pipeline with id "+pipelineID+" not found", r.URL.Path))
+		return
+	}
+	if enabled, _ := pipeline["enabled"].(bool); !enabled {
+		writeJSON(w, http.StatusBadRequest, exception(http.StatusBadRequest, "Pipeline "+pipeline["name"].(string)+" is not enabled", r.URL.Path))
+		return
+	}
+	if concurrency, _ := pipeline["concurrency"].(float64); concurrency > 0 && s.runningExecutions(pipeline) >= int(concurrency) {
+		writeJSON(w, http.StatusBadRequest, exception(http.StatusBadRequest, fmt.Sprintf("Pipeline %s has reached its concurrency limit of %d", pipeline["name"], int(concurrency)), r.URL.Path))
+		return
+	}
+	var request struct {
+		Comments string                 `json:"comments"`
+		Input    map[string]interface{} `json:"input"`
+		Tags     []string               `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, exception(http.StatusBadRequest, err.Error(), r.URL.Path))
+		return
+	}
+	index := 1
+	for _, execution := range s.documents[Executions] {
+		if execution["_pipelineLink"] == pipeline["_link"] {
+			index++
+		}
+	}
+	now := s.now()
+	execution := map[string]interface{}{
+		"name":                   pipeline["name"],
+		"project":                pipeline["project"],
+		"index":                  index,
+		"comments":               request.Comments,
+		"input":                  request.Input,
+		"output":                 map[string]interface{}{},
+		"tags":                   request.Tags,
+		"status":                 "COMPLETED",
+		"statusMessage":          "Execution Completed.",
+		"stageOrder":             pipeline["stageOrder"],
+		"stages":                 executionStages(pipeline),
+		"_executedBy":            s.Username,
+		"_pipelineLink":          pipeline["_link"],
+		"_requestTimeInMicros":   now,
+		"_durationInMicros":      0,
+		"_totalDurationInMicros": 0,
+		"_nested":                false,
+		"_rollback":              false,
+		"workspaceResults":       []interface{}{},
+	}
+	if s.ExecutionResult != nil {
+		s.ExecutionResult(execution)
+	}
+	id := s.add(Executions, execution)
+	if s.ExecutionPolls > 0 {
+		s.running[id] = s.ExecutionPolls
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"comments":      request.Comments,
+		"source":        "API",
+		"input":         request.Input,
+		"executionLink": execution["_link"],
+		"tags":          request.Tags,
+	})
+}
+
+// runningExecutions - the number of executions of the pipeline that have not finished
+func (s *Server) runningExecutions(pipeline map[string]interface{}) int {
+	var running int
+	for id := range s.running {
+		if s.documents[Executions][id]["_pipelineLink"] == pipeline["_link"] {
+			running++
+		}
+	}
+	return running
+}
+
+// executionStages - the pipeline stages and tasks, each marked COMPLETED
+func executionStages(pipeline map[string]interface{}) map[string]interface{} {
+	stages := map[string]interface{}{}
+	pipelineStages, _ := pipeline["stages"].(map[string]interface{})
+	for stageName, s := range pipelineStages {
+		stage, _ := s.(map[string]interface{})
+		tasks := map[string]interface{}{}
+		stageTasks, _ := stage["tasks"].(map[string]interface{})
+		for taskName, t := range stageTasks {
+			task, _ := t.(map[string]interface{})
+			tasks[taskName] = map[string]interface{}{
+				"name":   taskName,
+				"type":   task["type"],
+				"status": "COMPLETED",
+			}
+		}
+		stages[stageName] = map[string]interface{}{
+			"name":      stageName,
+			"status":    "COMPLETED",
+			"taskOrder": stage["taskOrder"],
+			"tasks":     tasks,
+		}
+	}
+	return stages
+}
+
+// listProjects - GET /project-service/api/projects
+func (s *Server) listProjects(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseFilter(r.URL.Query().Get("$filter"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, exception(http.StatusBadRequest, err.Error(), r.URL.Path))
+		return
+	}
+	content := []interface{}{}
+	for _, project := range s.projects {
+		if filter(project) {
+			content = append(content, project)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"content":          content,
+		"totalElements":    len(content),
+		"numberOfElements": len(content),
+	})
+}
+
+// importYaml - POST /pipeline/api/import?action=create|apply with a PIPELINE or ENDPOINT YAML body
+func (s *Server) importYaml(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	var parsed map[interface{}]interface{}
+	if err := yaml.Unmarshal(body, &parsed); err != nil {
+		writeJSON(w, http.StatusBadRequest, exception(http.StatusBadRequest, "Invalid YAML: "+err.Error(), r.URL.Path))
+		return
+	}
+	document, _ := jsonValue(parsed).(map[string]interface{})
+	name, _ := document["name"].(string)
+	project, _ := document["project"].(string)
+	kind, _ := document["kind"].(string)
+	action := r.URL.Query().Get("action")
+
+	var collection string
+	switch strings.ToUpper(kind) {
+	case "PIPELINE":
+		collection = Pipelines
+		if _, ok := document["enabled"]; !ok {
+			document["enabled"] = false
+		}
+		if enabled, _ := document["enabled"].(bool); enabled {
+			document["state"] = "ENABLED"
+		} else {
+			document["state"] = "DISABLED"
+		}
+	case "ENDPOINT":
+		collection = Endpoints
+	default:
+		writeImportResponse(w, name, "FAILED", "Unsupported kind '"+kind+"'")
+		return
+	}
+	if s.projectID(project) == "" {
+		writeImportResponse(w, name, "FAILED", "Project '"+project+"' does not exist")
+		return
+	}
+	existing := s.find(collection, name, project)
+	switch {
+	case existing != nil && action == "create":
+		writeImportResponse(w, name, "CONFLICT", "A "+strings.ToLower(kind)+" with name '"+name+"' already exists in project '"+project+"'")
+	case existing != nil:
+		for _, key := range []string{"id", "_link", "_createTimeInMicros"} {
+			document[key] = existing[key]
+		}
+		document["_updateTimeInMicros"] = s.now()
+		s.add(collection, document)
+		writeImportResponse(w, name, "UPDATED", "")
+	default:
+		s.add(collection, document)
+		writeImportResponse(w, name, "CREATED", "")
+	}
+}
+
+func writeImportResponse(w http.ResponseWriter, name, status, message string) {
+	b, _ := yaml.Marshal(yaml.MapSlice{
+		{Key: "name", Value: name},
+		{Key: "status", Value: status},
+		{Key: "statusMessage", Value: message},
+	})
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// Keys exported first, in this order, the rest follow alphabetically
+var exportKeys = map[string][]string{
+	Pipelines: {"project", "kind", "name", "icon", "enabled", "description", "concurrency", "input", "_inputMeta", "workspace", "stageOrder", "stages", "notifications", "options", "rollbacks", "tags"},
+	Endpoints: {"project", "kind", "name", "description", "type", "isRestricted", "properties"},
+}
+
+// exportYaml - GET /pipeline/api/export?pipelines=name&project=p (or endpoints=name)
+func (s *Server) exportYaml(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	for _, collection := range []string{Pipelines, Endpoints} {
+		name := query.Get(collection)
+		if name == "" {
+			continue
+		}
+		document := s.find(collection, name, query.Get("project"))
+		if document == nil {
+			writeJSON(w, http.StatusNotFound, exception(http.StatusNotFound, collection+" '"+name+"' not found", r.URL.Path))
+			return
+		}
+		var exported yaml.MapSlice
+		for _, key := range exportKeys[collection] {
+			if value, ok := document[key]; ok {
+				exported = append(exported, yaml.MapItem{Key: key, Value: yamlValue(value)})
+			}
+		}
+		b, _ := yaml.Marshal(exported)
+		w.Header().Set("Content-Type", "application/x-yaml")
+		w.WriteHeader(http.StatusOK)
+		w.Write(b)
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, exception(http.StatusBadRequest, "Specify pipelines or endpoints to export", r.URL.Path))
+}
+
+// jsonValue - converts a YAML value to its JSON equivalent (string map keys)
+func jsonValue(v interface{}) interface{} {
+	switch typed := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, value := range typed {
+			m[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range typed {
+			typed[i] = jsonValue(value)
+		}
+		return typed
+	case int:
+		return float64(typed)
+	}
+	return v
+}
+
+// yamlValue - converts a JSON value to YAML with sorted map keys
+func yamlValue(v interface{}) interface{} {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		var keys []string
+		for key := range typed {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var m yaml.MapSlice
+		for _, key := range keys {
+			m = append(m, yaml.MapItem{Key: key, Value: yamlValue(typed[key])})
+		}
+		return m
+	case []interface{}:
+		var values []interface{}
+		for _, value := range typed {
+			values = append(values, yamlValue(value))
+		}
+		return values
+	case float64:
+		if typed == float64(int64(typed)) {
+			return int64(typed)
+		}
+	}
+	return v
+}
@@ -0,0 +1,387 @@
+/*
+Package fakeserver Copyright 2021 VMware, Inc.
+SPDX-License-Identifier: BSD-2-Clause
+*/
+
+// Package fakeserver is an in-memory fake of the vRealize Automation Code Stream
+// API, used to test cs-cli end-to-end and to run offline demos.
+package fakeserver
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Collections served under /pipeline/api/<collection>
+const (
+	Pipelines          = "pipelines"
+	Executions         = "executions"
+	Variables          = "variables"
+	Endpoints          = "endpoints"
+	CustomIntegrations = "custom-integrations"
+)
+
+// Server - an in-memory Code Stream API. The zero value is not usable, use New.
+type Server struct {
+	// Username and Password accepted by the login APIs
+	Username string
+	Password string
+	// ExecutionResult is called for each new execution and may change its
+	// status, statusMessage, output or stages. Executions complete successfully
+	// when it is nil.
+	ExecutionResult func(execution map[string]interface{})
+	// ExecutionPolls is the number of times a new execution is reported as
+	// RUNNING before its final status. Running executions count against the
+	// pipeline concurrency, and executions over the limit are rejected.
+	ExecutionPolls int
+	// BeforeRequest is called before each request is handled, and may change
+	// the server state, e.g. to simulate other users
+	BeforeRequest func(r *http.Request)
+
+	mu            sync.Mutex
+	refreshTokens map[string]bool
+	accessTokens  map[string]bool
+	documents     map[string]map[string]map[string]interface{} // collection -> id -> document
+	order         map[string][]string                          // collection -> ids in creation order
+	projects      []map[string]interface{}
+	running       map[string]int         // execution id -> polls until it finishes
+	logs          map[string]interface{} // execution id -> workspace logs
+	sequence      int64
+}
+
+// New - returns an empty fake Code Stream server accepting the given credentials
+func New(username, password string) *Server {
+	return &Server{
+		Username:      username,
+		Password:      password,
+		refreshTokens: map[string]bool{},
+		accessTokens:  map[string]bool{},
+		documents:     map[string]map[string]map[string]interface{}{},
+		order:         map[string][]string{},
+		running:       map[string]int{},
+		logs:          map[string]interface{}{},
+	}
+}
+
+// ServeHTTP - routes a request to the fake API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.BeforeRequest != nil {
+		s.BeforeRequest(r)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case path == "/csp/gateway/am/api/login" || path == "/csp/gateway/am/idp/auth/login":
+		s.login(w, r)
+		return
+	case path == "/iaas/api/login":
+		s.iaasLogin(w, r)
+		return
+	case path == "/csp/gateway/am/api/auth/api-tokens/authorize":
+		s.cspAuthorize(w, r)
+		return
+	}
+
+	if !s.authorized(r) {
+		writeJSON(w, http.StatusUnauthorized, exception(http.StatusUnauthorized, "Unauthorized", r.URL.Path))
+		return
+	}
+	switch {
+	case path == "/pipeline/api/user-preferences":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"userName": s.Username, "preferences": map[string]interface{}{}})
+	case path == "/pipeline/api/import":
+		s.importYaml(w, r)
+	case path == "/pipeline/api/export":
+		s.exportYaml(w, r)
+	case path == "/project-service/api/projects":
+		s.listProjects(w, r)
+	case strings.HasPrefix(path, "/codestream/api/executions/") && strings.HasSuffix(path, "/logs"):
+		s.executionLogs(w, r, strings.TrimSuffix(strings.TrimPrefix(path, "/codestream/api/executions/"), "/logs"))
+	case strings.HasPrefix(path, "/codestream/api/executions/"):
+		s.document(w, r, Executions, strings.TrimPrefix(path, "/codestream/api/executions/"))
+	case strings.HasPrefix(path, "/pipeline/api/"):
+		parts := strings.Split(strings.TrimPrefix(path, "/pipeline/api/"), "/")
+		if _, ok := s.documents[parts[0]]; !ok && !isCollection(parts[0]) {
+			writeJSON(w, http.StatusNotFound, exception(http.StatusNotFound, "Not Found", r.URL.Path))
+			return
+		}
+		switch len(parts) {
+		case 1:
+			s.collection(w, r, parts[0])
+		case 2:
+			s.document(w, r, parts[0], parts[1])
+		case 3:
+			if parts[0] == Pipelines && parts[2] == Executions && r.Method == http.MethodPost {
+				s.createExecution(w, r, parts[1])
+				return
+			}
+			fallthrough
+		default:
+			writeJSON(w, http.StatusNotFound, exception(http.StatusNotFound, "Not Found", r.URL.Path))
+		}
+	default:
+		writeJSON(w, http.StatusNotFound, exception(http.StatusNotFound, "Not Found", r.URL.Path))
+	}
+}
+
+func isCollection(name string) bool {
+	switch name {
+	case Pipelines, Executions, Variables, Endpoints, CustomIntegrations:
+		return true
+	}
+	return false
+}
+
+// SetExecutionLogs - sets the workspace logs returned for an execution
+func (s *Server) SetExecutionLogs(id string, logs interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs[id] = logs
+}
+
+// ExpireAccessTokens - invalidates all access tokens, so clients have to refresh them
+func (s *Server) ExpireAccessTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessTokens = map[string]bool{}
+}
+
+// IssueAccessToken - returns a valid access token, as if injected by a secrets manager
+func (s *Server) IssueAccessToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.newToken(s.accessTokens, "access")
+}
+
+// AddProject - creates a project and returns its ID
+func (s *Server) AddProject(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := newID()
+	s.projects = append(s.projects, map[string]interface{}{
+		"id":          id,
+		"name":        name,
+		"description": "",
+	})
+	return id
+}
+
+// Add - stores a document in a collection and returns its ID. The id, _link and
+// timestamps are filled in when not set.
+func (s *Server) Add(collection string, document map[string]interface{}) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(collection, copyDocument(document))
+}
+
+// Get - returns a copy of a document, or nil if it does not exist
+func (s *Server) Get(collection, id string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyDocument(s.documents[collection][id])
+}
+
+// List - returns copies of the documents in a collection, in creation order
+func (s *Server) List(collection string) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var documents []map[string]interface{}
+	for _, id := range s.order[collection] {
+		documents = append(documents, copyDocument(s.documents[collection][id]))
+	}
+	return documents
+}
+
+// Find - returns a copy of the first document with the name (and project, if not empty)
+func (s *Server) Find(collection, name, project string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyDocument(s.find(collection, name, project))
+}
+
+func (s *Server) add(collection string, document map[string]interface{}) string {
+	id, _ := document["id"].(string)
+	if id == "" {
+		id = newID()
+		document["id"] = id
+	}
+	if _, ok := document["_link"]; !ok {
+		document["_link"] = "/codestream/api/" + collection + "/" + id
+	}
+	now := s.now()
+	timestamps := []string{"_createTimeInMicros", "_updateTimeInMicros"}
+	if collection == Executions {
+		timestamps = append(timestamps, "_requestTimeInMicros")
+	}
+	for _, key := range timestamps {
+		if _, ok := document[key]; !ok {
+			document[key] = now
+		}
+	}
+	if project, ok := document["project"].(string); ok {
+		if projectID := s.projectID(project); projectID != "" {
+			document["_projectId"] = projectID
+		}
+	}
+	if s.documents[collection] == nil {
+		s.documents[collection] = map[string]map[string]interface{}{}
+	}
+	if _, exists := s.documents[collection][id]; !exists {
+		s.order[collection] = append(s.order[collection], id)
+	}
+	s.documents[collection][id] = document
+	return id
+}
+
+func (s *Server) remove(collection, id string) {
+	delete(s.documents[collection], id)
+	for i, existing := range s.order[collection] {
+		if existing == id {
+			s.order[collection] = append(s.order[collection][:i], s.order[collection][i+1:]...)
+			break
+		}
+	}
+}
+
+func (s *Server) find(collection, name, project string) map[string]interface{} {
+	for _, id := range s.order[collection] {
+		document := s.documents[collection][id]
+		if document["name"] == name && (project == "" || document["project"] == project) {
+			return document
+		}
+	}
+	return nil
+}
+
+func (s *Server) projectID(name string) string {
+	for _, p := range s.projects {
+		if p["name"] == name {
+			return p["id"].(string)
+		}
+	}
+	return ""
+}
+
+// now - returns a strictly increasing timestamp in microseconds, so documents
+// created in the same microsecond still sort in creation order
+func (s *Server) now() int64 {
+	now := time.Now().UnixNano() / 1000
+	if now <= s.sequence {
+		now = s.sequence + 1
+	}
+	s.sequence = now
+	return now
+}
+
+func (s *Server) newToken(tokens map[string]bool, prefix string) string {
+	token := prefix + "-" + newID()
+	tokens[token] = true
+	return token
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	return strings.HasPrefix(header, "Bearer ") && s.accessTokens[strings.TrimPrefix(header, "Bearer ")]
+}
+
+// login - /csp/gateway/am/api/login, returns a refresh token for valid credentials
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	if body.Username != s.Username || body.Password != s.Password {
+		writeJSON(w, http.StatusUnauthorized, map[string]interface{}{
+			"status":        "401",
+			"serverMessage": "Invalid username or password",
+		})
+		return
+	}
+	refreshToken := s.newToken(s.refreshTokens, "refresh")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"refresh_token": refreshToken,
+		"access_token":  s.newToken(s.accessTokens, "access"),
+		"token_type":    "bearer",
+	})
+}
+
+// iaasLogin - /iaas/api/login, exchanges a refresh token for an access token (vRA On-premises)
+func (s *Server) iaasLogin(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+	if !s.refreshTokens[body.RefreshToken] {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"message": "Invalid refresh token", "statusCode": 400})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tokenType": "Bearer", "token": s.newToken(s.accessTokens, "access")})
+}
+
+// cspAuthorize - exchanges an API token for an access token (vRA Cloud)
+func (s *Server) cspAuthorize(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if !s.refreshTokens[r.Form.Get("refresh_token")] {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{"message": "Invalid refresh token", "statusCode": 400})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"token_type": "bearer", "access_token": s.newToken(s.accessTokens, "access")})
+}
+
+// IssueRefreshToken - returns a valid API (refresh) token
+func (s *Server) IssueRefreshToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.newToken(s.refreshTokens, "refresh")
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func exception(status int, message, path string) map[string]interface{} {
+	return map[string]interface{}{
+		"timestamp": time.Now().UnixNano() / int64(time.Millisecond),
+		"path":      path,
+		"status":    status,
+		"error":     http.StatusText(status),
+		"message":   message,
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// copyDocument - returns a deep copy of a JSON document
+func copyDocument(document map[string]interface{}) map[string]interface{} {
+	if document == nil {
+		return nil
+	}
+	b, _ := json.Marshal(document)
+	var c map[string]interface{}
+	json.Unmarshal(b, &c)
+	return c
+}
+
+func intParam(r *http.Request, name string, defaultValue int) int {
+	if value, err := strconv.Atoi(r.URL.Query().Get(name)); err == nil {
+		return value
+	}
+	return defaultValue
+}